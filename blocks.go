@@ -0,0 +1,171 @@
+package qwick
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/klauspost/compress/s2"
+	"github.com/klauspost/compress/zstd"
+)
+
+// blockRefSize - размер записи таблицы блоков: Off(8) + CompressedLen(4) + DecodedLen(4).
+const blockRefSize = uint64(8 + 4 + 4)
+
+// blockCacheSize - число распакованных блоков, которые держит кэш одной базы.
+const blockCacheSize = 8
+
+// blockRef описывает один сжатый блок значений.
+type blockRef struct {
+	off  uint64
+	clen uint32
+	dlen uint32
+}
+
+// packBlockRef упаковывает номер блока и смещение внутри него в поле voff индекса.
+func packBlockRef(block, inner uint64) uint64 {
+	return block<<32 | inner
+}
+
+func unpackBlockRef(voff uint64) (block, inner uint64) {
+	return voff >> 32, voff & 0xFFFFFFFF
+}
+
+func encodeBlockTable(blocks []blockRef) []byte {
+	buf := make([]byte, uint64(len(blocks))*blockRefSize)
+	for i, br := range blocks {
+		off := uint64(i) * blockRefSize
+		binary.LittleEndian.PutUint64(buf[off:off+8], br.off)
+		binary.LittleEndian.PutUint32(buf[off+8:off+12], br.clen)
+		binary.LittleEndian.PutUint32(buf[off+12:off+16], br.dlen)
+	}
+	return buf
+}
+
+// blockCache хранит несколько последних распакованных блоков, чтобы префиксный
+// обход распаковывал каждый блок один раз. Буферы блоков никогда не переиспользуются,
+// поэтому выданные срезы остаются валидными после вытеснения.
+type blockCache struct {
+	mu      sync.Mutex
+	tick    uint64
+	entries [blockCacheSize]struct {
+		id   uint64
+		data []byte
+		used uint64
+	}
+}
+
+func (c *blockCache) get(id uint64) []byte {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for i := range c.entries {
+		e := &c.entries[i]
+		if e.data != nil && e.id == id {
+			c.tick++
+			e.used = c.tick
+			return e.data
+		}
+	}
+	return nil
+}
+
+func (c *blockCache) put(id uint64, data []byte) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	victim := 0
+	for i := range c.entries {
+		if c.entries[i].data == nil {
+			victim = i
+			break
+		}
+		if c.entries[i].used < c.entries[victim].used {
+			victim = i
+		}
+	}
+	c.tick++
+	c.entries[victim].id = id
+	c.entries[victim].data = data
+	c.entries[victim].used = c.tick
+}
+
+// block возвращает распакованный блок с номером id.
+func (db *MMAPDB) block(id uint64) ([]byte, error) {
+	if data := db.bcache.get(id); data != nil {
+		return data, nil
+	}
+
+	if id >= uint64(len(db.blocks))/blockRefSize {
+		return nil, fmt.Errorf("блок %d вне таблицы блоков", id)
+	}
+	ref := db.blocks[id*blockRefSize : (id+1)*blockRefSize]
+	off := binary.LittleEndian.Uint64(ref[0:8])
+	clen := uint64(binary.LittleEndian.Uint32(ref[8:12]))
+	dlen := binary.LittleEndian.Uint32(ref[12:16])
+	raw := db.at(off, clen)
+	if raw == nil {
+		return nil, fmt.Errorf("блок %d выходит за границы файла", id)
+	}
+
+	var (
+		data  []byte
+		err   error
+		start time.Time
+	)
+	if db.obs != nil {
+		start = time.Now()
+	}
+	// Буфер выделяется по dlen из таблицы только после сверки с заголовком сжатых
+	// данных: испорченная таблица не должна приводить к огромной аллокации.
+	switch db.compression {
+	case compZstd:
+		// Маленькие кадры пишутся без размера содержимого: тогда буфер растёт
+		// при распаковке, а её объём ограничен декодером.
+		var h zstd.Header
+		if h.Decode(raw) != nil || h.HasFCS && h.FrameContentSize != uint64(dlen) {
+			return nil, fmt.Errorf("блок %d: %w", id, errCorruptValue)
+		}
+		var buf []byte
+		if h.HasFCS {
+			buf = make([]byte, 0, dlen)
+		}
+		data, err = db.zstdDecoder().DecodeAll(raw, buf)
+	case compS2:
+		if n, derr := s2.DecodedLen(raw); derr != nil || n != int(dlen) {
+			return nil, fmt.Errorf("блок %d: %w", id, errCorruptValue)
+		}
+		data, err = s2.Decode(make([]byte, dlen), raw)
+	default:
+		data = raw
+	}
+	if db.obs != nil {
+		db.obs.OnDecode(len(data), time.Since(start), err)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if uint32(len(data)) != dlen {
+		return nil, fmt.Errorf("блок %d: %w", id, errCorruptValue)
+	}
+	db.bcache.put(id, data)
+	return data, nil
+}
+
+// blockValue возвращает значение записи i из распакованного блока.
+func (db *MMAPDB) blockValue(i uint64) ([]byte, error) {
+	e, ok := db.readIndex(i)
+	if !ok {
+		return nil, errors.New("ошибка чтения индекса")
+	}
+	id, inner := unpackBlockRef(e.voff)
+	data, err := db.block(id)
+	if err != nil {
+		return nil, err
+	}
+	end := inner + e.vlen
+	if end > uint64(len(data)) {
+		return nil, errors.New("значение выходит за границы блока")
+	}
+	return data[inner:end:end], nil
+}
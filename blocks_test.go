@@ -0,0 +1,117 @@
+package qwick
+
+import (
+	"encoding/binary"
+	"errors"
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+
+	art "github.com/plar/go-adaptive-radix-tree/v2"
+)
+
+func TestBlockLayout(t *testing.T) {
+	tmpDir, _ := os.MkdirTemp("", "qwick_blocks")
+	defer os.RemoveAll(tmpDir)
+
+	tree := jsonTree(3000)
+	valuesPath := filepath.Join(tmpDir, "values.qwick")
+	blocksPath := filepath.Join(tmpDir, "blocks.qwick")
+
+	if err := BuildWithOptions(tree, valuesPath, BuildOptions{Compression: compZstd}); err != nil {
+		t.Fatalf("Ошибка BuildWithOptions: %v", err)
+	}
+	err := BuildWithOptions(tree, blocksPath, BuildOptions{Layout: LayoutBlocks, BlockSize: 16 << 10})
+	if err != nil {
+		t.Fatalf("Ошибка BuildWithOptions (блоки): %v", err)
+	}
+
+	valuesInfo, _ := os.Stat(valuesPath)
+	blocksInfo, _ := os.Stat(blocksPath)
+	if blocksInfo.Size() >= valuesInfo.Size() {
+		t.Errorf("Блочная раскладка не уменьшила размер: %d >= %d", blocksInfo.Size(), valuesInfo.Size())
+	}
+
+	db, err := Open(blocksPath)
+	if err != nil {
+		t.Fatalf("Ошибка Open: %v", err)
+	}
+	defer db.Close()
+
+	tree.ForEach(func(n art.Node) bool {
+		val, ok, err := db.Find(n.Key(), nil)
+		if !ok || err != nil || string(val) != string(n.Value().([]byte)) {
+			t.Fatalf("Ошибка Find %s: ok %v, err %v", n.Key(), ok, err)
+		}
+		raw, ok := db.GetRaw(n.Key())
+		if !ok || string(raw) != string(n.Value().([]byte)) {
+			t.Fatalf("Ошибка GetRaw %s", n.Key())
+		}
+		return true
+	})
+
+	count := 0
+	err = db.Prefix([]byte("user:001"), nil, func(k, v []byte) bool {
+		if len(v) == 0 {
+			t.Errorf("Пустое значение для %s", k)
+		}
+		count++
+		return true
+	})
+	if err != nil || count != 1000 {
+		t.Errorf("Ошибка Prefix: получено %d, err %v", count, err)
+	}
+
+	for _, comp := range []uint32{compZstd, compS2} {
+		path := filepath.Join(tmpDir, "comp.qwick")
+		if err := BuildWithOptions(tree, path, BuildOptions{Compression: comp, Layout: LayoutBlocks}); err != nil {
+			t.Fatalf("Ошибка BuildWithOptions (сжатие %d): %v", comp, err)
+		}
+		db, err := Open(path)
+		if err != nil {
+			t.Fatalf("Ошибка Open: %v", err)
+		}
+		val, ok, err := db.Find([]byte("user:002999"), nil)
+		if !ok || err != nil || len(val) == 0 {
+			t.Errorf("Ошибка Find (сжатие %d): ok %v, err %v", comp, ok, err)
+		}
+		db.Close()
+	}
+}
+
+func TestBlockCache(t *testing.T) {
+	var c blockCache
+	for i := uint64(0); i < blockCacheSize*2; i++ {
+		c.put(i, []byte{byte(i)})
+	}
+	if c.get(0) != nil {
+		t.Error("Старый блок должен быть вытеснен")
+	}
+	if v := c.get(blockCacheSize*2 - 1); v == nil || v[0] != blockCacheSize*2-1 {
+		t.Error("Последний блок должен быть в кэше")
+	}
+}
+
+func TestBlockCorruptSize(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "blocks.qwick")
+	for _, comp := range []uint32{compZstd, compS2} {
+		if err := BuildWithOptions(jsonTree(300), path, BuildOptions{Layout: LayoutBlocks, Compression: comp}); err != nil {
+			t.Fatalf("Ошибка BuildWithOptions: %v", err)
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		db, err := OpenBytes(data)
+		if err != nil {
+			t.Fatalf("Ошибка OpenBytes: %v", err)
+		}
+		// Таблица блоков лежит в data: заявленный размер блока 0 - почти 4GB.
+		binary.LittleEndian.PutUint32(db.blocks[12:16], math.MaxUint32-1)
+		if _, _, err := db.Find([]byte("user:000001"), nil); !errors.Is(err, errCorruptValue) {
+			t.Errorf("сжатие %d: ожидалась errCorruptValue, получено %v", comp, err)
+		}
+		db.Close()
+	}
+}
@@ -0,0 +1,749 @@
+package qwick
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/klauspost/compress/s2"
+	"github.com/klauspost/compress/zstd"
+	art "github.com/plar/go-adaptive-radix-tree/v2"
+)
+
+// Раскладка значений в файле.
+const (
+	LayoutValues = 0 // каждое значение сжимается отдельно
+	LayoutBlocks = 1 // значения группируются в блоки, блок сжимается целиком
+)
+
+const defaultBlockSize = 32 << 10 // 32KB
+
+// deterministicAlign - выравнивание индекса и секций в детерминированном режиме.
+const deterministicAlign = 8
+
+// BuildOptions управляет настройками компрессии при сборке базы.
+type BuildOptions struct {
+	Compression uint32 // 0=auto, 1=zstd, 2=s2
+	ZstdLevel   int    // 1..3 уровни скорости
+	SizeCutover int    // порог выбора между s2 и zstd для режима auto
+
+	// TrainDictionary включает обучение словаря zstd по выборке значений.
+	// Все значения сжимаются zstd со словарём, словарь сохраняется в файле.
+	TrainDictionary DictOptions
+
+	// Layout задаёт раскладку значений: LayoutValues или LayoutBlocks.
+	// В блочной раскладке соседние значения собираются в блоки по BlockSize байт
+	// (0 = 32KB), что даёт лучшее сжатие мелких значений ценой распаковки блока на чтение.
+	Layout    int
+	BlockSize int
+
+	// Deterministic гарантирует побайтно одинаковый результат для одинаковых данных:
+	// параметры zstd фиксируются явно, а индекс и секции выравниваются по 8 байт.
+	// Обучение словаря в этом режиме недоступно - его результат не воспроизводим.
+	Deterministic bool
+
+	// Metadata - произвольные пары, сохраняемые в файле (идентификатор снимка,
+	// версия схемы, владелец и т.п.). Читаются через MMAPDB.Metadata.
+	Metadata map[string][]byte
+
+	// Indexes объявляет вторичные индексы: имя -> функция извлечения ключей.
+	// Индексы хранятся отдельными секциями и доступны через MMAPDB.Index.
+	Indexes map[string]IndexFunc
+
+	// SortValues сортирует значения повторяющихся ключей (Values) по возрастанию
+	// байт, что нужно для MMAPDB.Intersect.
+	SortValues bool
+
+	// Dedupe записывает одинаковые хранимые значения один раз: записи индекса
+	// с равными значениями ссылаются на общий участок файла. Полезно, когда
+	// множество ключей разделяют немногие различные значения. Только для LayoutValues.
+	Dedupe bool
+
+	// Report, если задан, после сборки получает её итоги (BuildReport).
+	Report *BuildReport
+
+	dict []byte // готовый словарь zstd (например, словарь исходного файла при слиянии)
+}
+
+// builder последовательно пишет ключи, значения, индекс и секции и собирает заголовок.
+// Запись идёт строго вперёд, поэтому результат можно направить в любой io.Writer.
+type builder struct {
+	w           *bufio.Writer
+	off         uint64
+	opts        BuildOptions
+	compression uint32
+	dict        []byte
+	zenc        *zstd.Encoder
+	indices     []indexEntry
+	sections    []section
+
+	// Блочная раскладка
+	blockBuf []byte
+	blocks   []blockRef
+
+	tombs   []uint64 // номера записей-надгробий
+	expires []uint64 // сроки действия записей (UnixNano), nil - все бессрочные
+
+	hash hash.Hash // хэш содержимого после заголовка
+
+	secondary map[string][]indexPair // ключи вторичных индексов
+
+	dups    bool   // записаны повторяющиеся ключи
+	lastKey []byte // ключ последней записи
+
+	spaces []spaceEntry // записанные пространства имён
+	nested bool         // сборщик пространства имён внутри общего файла
+
+	seen map[[sha256.Size]byte]valueRef // записанные значения (Dedupe)
+
+	stats Stats // статистика сборки
+}
+
+// valueRef - положение записанного значения.
+type valueRef struct {
+	off uint64
+	n   uint64
+}
+
+func newBuilder(w io.Writer, opts BuildOptions) (*builder, error) {
+	if err := opts.validate(); err != nil {
+		return nil, err
+	}
+	return &builder{w: bufio.NewWriterSize(w, 1<<20), opts: opts}, nil
+}
+
+func (opts *BuildOptions) validate() error {
+	if opts.Layout != LayoutValues && opts.Layout != LayoutBlocks {
+		return fmt.Errorf("неизвестная раскладка значений: %d", opts.Layout)
+	}
+	if opts.Dedupe && opts.Layout != LayoutValues {
+		return errors.New("Dedupe поддерживается только раскладкой LayoutValues")
+	}
+	return nil
+}
+
+// BuildWithOptions сериализует ART дерево в файл с заданными опциями.
+// Заголовок пишется в начало файла.
+func BuildWithOptions(tree art.Tree, path string, opts BuildOptions) error {
+	return buildFile(path, opts, tree, fillTree(tree))
+}
+
+// BuildTo сериализует ART дерево в w без перемещений по файлу: индекс, секции и
+// заголовок пишутся в конец (footer), а в начале остаётся короткая метка с флагом.
+// Open распознаёт оба варианта раскладки.
+func BuildTo(w io.Writer, tree art.Tree, opts BuildOptions) error {
+	return buildTo(w, opts, tree, fillTree(tree))
+}
+
+// buildTo собирает поток с заголовком в конце (см. BuildTo).
+func buildTo(w io.Writer, opts BuildOptions, tree art.Tree, fill func(b *builder) error) error {
+	b, err := newBuilder(w, opts)
+	if err != nil {
+		return err
+	}
+	defer b.close()
+
+	hdr, err := b.run(tree, true, fill)
+	if err != nil {
+		return err
+	}
+	if err := b.write(hdr); err != nil {
+		return err
+	}
+	return b.w.Flush()
+}
+
+// buildFile собирает файл path через временный файл с заголовком в начале.
+// fill передаёт записи сборщику; tree нужно только для обучения словаря и может быть nil.
+func buildFile(path string, opts BuildOptions, tree art.Tree, fill func(b *builder) error) (err error) {
+	dir := filepath.Dir(path)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return fmt.Errorf("ошибка создания директории %s: %w", dir, err)
+	}
+
+	tmp := path + ".tmp"
+	f, err := os.Create(tmp)
+	if err != nil {
+		return fmt.Errorf("ошибка создания временного файла: %w", err)
+	}
+	defer func() {
+		f.Close()
+		if err != nil {
+			os.Remove(tmp)
+		}
+	}()
+
+	b, err := newBuilder(f, opts)
+	if err != nil {
+		return err
+	}
+	defer b.close()
+
+	hdr, err := b.run(tree, false, fill)
+	if err != nil {
+		return err
+	}
+	if _, err := f.WriteAt(hdr, 0); err != nil {
+		return fmt.Errorf("ошибка записи заголовка: %w", err)
+	}
+
+	if err := f.Sync(); err != nil {
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// fillTree передаёт сборщику все пары дерева по порядку ключей.
+func fillTree(tree art.Tree) func(b *builder) error {
+	return func(b *builder) error {
+		var err error
+		tree.ForEach(func(n art.Node) (cont bool) {
+			err = b.addValue(n.Key(), n.Value())
+			return err == nil
+		}, art.TraverseLeaf)
+		return err
+	}
+}
+
+// addValue дописывает значение из дерева с учётом его типа.
+func (b *builder) addValue(key []byte, val any) error {
+	switch v := val.(type) {
+	case tombstone:
+		return b.addTombstone(key)
+	case Values:
+		return b.addValues(key, v)
+	case Expiring:
+		return b.addExpiring(key, v)
+	default:
+		return b.add(key, valueBytes(v))
+	}
+}
+
+// run пишет файл целиком и возвращает итоговый заголовок.
+// Для footer=true заголовок нужно дописать в конец, иначе - записать по смещению 0.
+func (b *builder) run(tree art.Tree, footer bool, fill func(b *builder) error) ([]byte, error) {
+	var rep BuildReport
+	start := time.Now()
+	if err := b.init(tree); err != nil {
+		return nil, err
+	}
+	rep.Train = time.Since(start)
+	if err := b.begin(footer); err != nil {
+		return nil, err
+	}
+	b.hash = sha256.New()
+	t := time.Now()
+	if err := fill(b); err != nil {
+		return nil, err
+	}
+	rep.Fill = time.Since(t)
+
+	t = time.Now()
+	hdr, err := b.finish()
+	if err != nil {
+		return nil, err
+	}
+	if footer {
+		hdr.Flags |= flagFooter
+	}
+	if err := b.w.Flush(); err != nil {
+		return nil, err
+	}
+	rep.Finish = time.Since(t)
+	rep.Total = time.Since(start)
+	if b.opts.Report != nil {
+		rep.Stats = b.stats
+		rep.FileBytes = b.off
+		if footer {
+			rep.FileBytes += headerSize
+		}
+		*b.opts.Report = rep
+	}
+	return hdr.encode(), nil
+}
+
+// begin пишет место под заголовок. В footer-раскладке это метка с сигнатурой и флагом,
+// по которой Open понимает, что настоящий заголовок лежит в конце файла.
+func (b *builder) begin(footer bool) error {
+	stub := fileHeader{}
+	if footer {
+		copy(stub.Magic[:], FileMagic)
+		stub.Version = FileVersion
+		stub.Flags = flagFooter
+	}
+	return b.write(stub.encode())
+}
+
+// init выбирает сжатие, обучает словарь и создаёт энкодер.
+func (b *builder) init(tree art.Tree) error {
+	opts := b.opts
+	b.compression = opts.Compression
+	if opts.Layout == LayoutBlocks && b.compression == 0 {
+		// Блоки большие, авто-режим для них всегда выбирает zstd.
+		b.compression = compZstd
+	}
+
+	level := zstd.SpeedFastest
+	if opts.ZstdLevel == 2 {
+		level = zstd.SpeedDefault
+	} else if opts.ZstdLevel == 3 {
+		level = zstd.SpeedBetterCompression
+	}
+
+	b.dict = opts.dict
+	if opts.TrainDictionary.DictSize > 0 {
+		if opts.Deterministic {
+			return errors.New("обучение словаря недоступно в детерминированном режиме")
+		}
+		if tree == nil {
+			return errors.New("обучение словаря требует дерева с исходными данными")
+		}
+		d, err := trainDict(tree, opts.TrainDictionary, level)
+		if err != nil {
+			return err
+		}
+		b.dict = d
+	}
+	if b.dict != nil {
+		if b.compression == compS2 {
+			return errors.New("словарь поддерживается только для zstd")
+		}
+		// Со словарём все значения сжимаются zstd, порог SizeCutover не используется.
+		b.compression = compZstd
+	}
+
+	// Для Zstd в режиме авто энкодер понадобится для крупных значений.
+	if b.compression == compZstd || (b.compression == 0 && opts.SizeCutover > 0) {
+		if b.compression == 0 {
+			level = zstd.SpeedFastest
+		}
+		encOpts := []zstd.EOption{zstd.WithEncoderLevel(level)}
+		if opts.Deterministic {
+			// Не полагаемся на умолчания библиотеки: они могут смениться между версиями.
+			encOpts = append(encOpts,
+				zstd.WithEncoderConcurrency(1),
+				zstd.WithWindowSize(1<<20),
+				zstd.WithEncoderCRC(true),
+				zstd.WithZeroFrames(false),
+				zstd.WithLowerEncoderMem(false),
+			)
+		}
+		if b.dict != nil {
+			encOpts = append(encOpts, zstd.WithEncoderDict(b.dict))
+		}
+		zenc, err := zstd.NewWriter(nil, encOpts...)
+		if err != nil {
+			return fmt.Errorf("ошибка создания zstd-энкодера: %w", err)
+		}
+		b.zenc = zenc
+	}
+	return nil
+}
+
+func (b *builder) close() {
+	if b.zenc != nil {
+		b.zenc.Close()
+	}
+}
+
+func (b *builder) write(p []byte) error {
+	n, err := b.w.Write(p)
+	b.off += uint64(n)
+	if b.hash != nil {
+		b.hash.Write(p[:n])
+	}
+	return err
+}
+
+// align дописывает нули до границы выравнивания в детерминированном режиме.
+func (b *builder) align() error {
+	if !b.opts.Deterministic {
+		return nil
+	}
+	var pad [deterministicAlign]byte
+	return b.write(pad[:(deterministicAlign-b.off%deterministicAlign)%deterministicAlign])
+}
+
+// add дописывает очередную пару ключ-значение. Ключи должны идти по возрастанию.
+func (b *builder) add(key, val []byte) error {
+	if err := checkKey(key); err != nil {
+		return err
+	}
+	if b.opts.Layout == LayoutBlocks && uint64(len(val)) > narrowLimit {
+		return fmt.Errorf("значение ключа %q (%d байт) не помещается в блок: используйте LayoutValues", key, len(val))
+	}
+	if len(b.opts.Indexes) > 0 {
+		b.extract(key, val, uint64(len(b.indices)))
+	}
+	b.countValue(key, len(val))
+	b.noteKey(key)
+	koff := b.off
+	if err := b.write(key); err != nil {
+		return err
+	}
+	klen := uint32(len(key))
+
+	if b.opts.Layout == LayoutBlocks {
+		return b.addToBlock(koff, klen, val)
+	}
+
+	// Огромные значения хранятся несжатыми, чтобы их можно было читать по частям.
+	raw := uint64(len(val)) > narrowLimit
+	cv := val
+	var voff uint64
+	var err error
+	if raw {
+		b.countCodec(compNone)
+		b.countBytes(compNone, len(cv), len(cv))
+		b.stats.StoredBytes += uint64(len(cv))
+		voff = b.off
+		err = b.write(cv)
+	} else {
+		var comp uint32
+		var shared bool
+		cv, comp = b.encode(val)
+		if voff, shared, err = b.writeValue(cv); !shared {
+			b.countCodec(comp)
+			b.countBytes(comp, len(val), len(cv))
+		}
+	}
+	if err != nil {
+		return err
+	}
+	b.indices = append(b.indices, indexEntry{koff, klen, voff, uint64(len(cv)), raw})
+	return nil
+}
+
+// checkKey проверяет, что длина ключа помещается в индекс.
+func checkKey(key []byte) error {
+	if uint64(len(key)) > math.MaxUint32 {
+		return fmt.Errorf("ключ длиной %d байт превышает предел 4GB", len(key))
+	}
+	return nil
+}
+
+// addEncoded дописывает значение, уже сжатое тем же кодеком, что и у сборщика, без перекодирования.
+func (b *builder) addEncoded(key, stored []byte) error {
+	shared, err := b.addStored(key, stored)
+	if !shared {
+		b.stats.Copied++
+	}
+	return err
+}
+
+// noteKey отмечает повтор ключа предыдущей записи: файл получает flagDups.
+func (b *builder) noteKey(key []byte) {
+	if len(b.indices) > 0 && bytes.Equal(key, b.lastKey) {
+		b.dups = true
+	}
+	b.lastKey = append(b.lastKey[:0], key...)
+}
+
+// addStored дописывает ключ и хранимое значение как есть и сообщает, что значение
+// записано ссылкой на копию (Dedupe).
+func (b *builder) addStored(key, stored []byte) (bool, error) {
+	if err := checkKey(key); err != nil {
+		return false, err
+	}
+	b.stats.Entries++
+	b.stats.KeyBytes += uint64(len(key))
+	b.stats.MaxKey = max(b.stats.MaxKey, uint64(len(key)))
+	b.stats.KeySizes.add(uint64(len(key)))
+	b.noteKey(key)
+	koff := b.off
+	if err := b.write(key); err != nil {
+		return false, err
+	}
+	voff, shared, err := b.writeValue(stored)
+	if err != nil {
+		return false, err
+	}
+	b.indices = append(b.indices, indexEntry{koff, uint32(len(key)), voff, uint64(len(stored)), false})
+	return shared, nil
+}
+
+// writeValue дописывает хранимое значение и возвращает его смещение. В режиме
+// Dedupe повторное значение не пишется (shared), а возвращается смещение первой копии;
+// пустые значения (надгробия) общими не считаются.
+func (b *builder) writeValue(stored []byte) (off uint64, shared bool, err error) {
+	if !b.opts.Dedupe || len(stored) == 0 {
+		off := b.off
+		b.stats.StoredBytes += uint64(len(stored))
+		return off, false, b.write(stored)
+	}
+	sum := sha256.Sum256(stored)
+	if ref, ok := b.seen[sum]; ok && ref.n == uint64(len(stored)) {
+		b.stats.Shared++
+		return ref.off, true, nil
+	}
+	if b.seen == nil {
+		b.seen = make(map[[sha256.Size]byte]valueRef)
+	}
+	off = b.off
+	b.seen[sum] = valueRef{off, uint64(len(stored))}
+	b.stats.StoredBytes += uint64(len(stored))
+	return off, false, b.write(stored)
+}
+
+// uncompressed сообщает, что ни одно значение не будет сжато.
+func (b *builder) uncompressed() bool {
+	return b.opts.Layout == LayoutValues && b.compression == 0 && b.opts.SizeCutover <= 0
+}
+
+// encode сжимает одно значение согласно настройкам сборки и возвращает выбранный кодек.
+func (b *builder) encode(vb []byte) ([]byte, uint32) {
+	compToUse := b.compression
+	if b.opts.Compression == 0 && b.opts.SizeCutover > 0 && b.dict == nil {
+		if len(vb) > b.opts.SizeCutover {
+			compToUse = compZstd
+		} else {
+			compToUse = compS2
+		}
+	}
+
+	switch compToUse {
+	case compZstd:
+		return b.zenc.EncodeAll(vb, nil), compToUse
+	case compS2:
+		return s2.Encode(nil, vb), compToUse
+	default:
+		return vb, compToUse
+	}
+}
+
+// addToBlock добавляет значение в текущий блок; индекс указывает на блок и смещение в нём.
+func (b *builder) addToBlock(koff uint64, klen uint32, val []byte) error {
+	// Длина блока и смещение внутри него 32-битные: не даём блоку их переполнить.
+	if uint64(len(b.blockBuf))+uint64(len(val)) > narrowLimit {
+		if err := b.flushBlock(); err != nil {
+			return err
+		}
+	}
+	b.countCodec(b.compression)
+	ref := packBlockRef(uint64(len(b.blocks)), uint64(len(b.blockBuf)))
+	b.blockBuf = append(b.blockBuf, val...)
+	b.indices = append(b.indices, indexEntry{koff, klen, ref, uint64(len(val)), false})
+
+	blockSize := b.opts.BlockSize
+	if blockSize <= 0 {
+		blockSize = defaultBlockSize
+	}
+	if len(b.blockBuf) >= blockSize {
+		return b.flushBlock()
+	}
+	return nil
+}
+
+// flushBlock сжимает и записывает накопленный блок.
+func (b *builder) flushBlock() error {
+	if len(b.blockBuf) == 0 {
+		return nil
+	}
+	var cb []byte
+	switch b.compression {
+	case compZstd:
+		cb = b.zenc.EncodeAll(b.blockBuf, nil)
+	case compS2:
+		cb = s2.Encode(nil, b.blockBuf)
+	default:
+		cb = b.blockBuf
+	}
+	if uint64(len(cb)) > narrowLimit {
+		return fmt.Errorf("блок %d после сжатия занимает %d байт: уменьшите BlockSize или используйте LayoutValues", len(b.blocks), len(cb))
+	}
+	b.blocks = append(b.blocks, blockRef{off: b.off, clen: uint32(len(cb)), dlen: uint32(len(b.blockBuf))})
+	b.stats.StoredBytes += uint64(len(cb))
+	b.countBytes(b.compression, len(b.blockBuf), len(cb))
+	if err := b.write(cb); err != nil {
+		return err
+	}
+	b.blockBuf = b.blockBuf[:0]
+	return nil
+}
+
+// addSection дописывает содержимое секции в текущую позицию файла.
+func (b *builder) addSection(kind uint32, data []byte) error {
+	if err := b.align(); err != nil {
+		return err
+	}
+	b.sections = append(b.sections, section{kind: kind, off: b.off, size: uint64(len(data))})
+	return b.write(data)
+}
+
+// finish дописывает индекс, секции и таблицу секций и возвращает заголовок.
+func (b *builder) finish() (fileHeader, error) {
+	hdr := fileHeader{
+		Version:     2, // версия 3 нужна только широкому индексу
+		NumEntries:  uint64(len(b.indices)),
+		OffBlobs:    headerSize,
+		ValueFmt:    100,
+		Compression: b.compression,
+	}
+	copy(hdr.Magic[:], FileMagic)
+
+	if b.uncompressed() {
+		hdr.Flags |= flagUncompressed
+	}
+	if b.dups {
+		hdr.Flags |= flagDups
+		if b.opts.SortValues {
+			hdr.Flags |= flagSortedValues
+		}
+	}
+	if b.opts.Layout == LayoutBlocks {
+		if err := b.flushBlock(); err != nil {
+			return hdr, err
+		}
+		hdr.Flags |= flagBlocks
+	}
+
+	if err := b.align(); err != nil {
+		return hdr, err
+	}
+	hdr.OffIndex = b.off
+	wide := false
+	for _, it := range b.indices {
+		if it.raw || it.vlen > narrowLimit {
+			wide = true
+			break
+		}
+	}
+	if wide {
+		hdr.Version = FileVersion
+		hdr.Flags |= flagWide
+	}
+	if err := writeIndex(b.write, b.indices, wide); err != nil {
+		return hdr, err
+	}
+	b.stats.IndexBytes += b.off - hdr.OffIndex
+
+	if b.opts.Layout == LayoutBlocks {
+		if err := b.addSection(secBlocks, encodeBlockTable(b.blocks)); err != nil {
+			return hdr, err
+		}
+	}
+	if b.dict != nil {
+		if err := b.addSection(secZstdDict, b.dict); err != nil {
+			return hdr, err
+		}
+	}
+	if err := b.addIndexSections(); err != nil {
+		return hdr, err
+	}
+	if len(b.opts.Metadata) > 0 {
+		if err := b.addSection(secMeta, encodeMetadata(b.opts.Metadata)); err != nil {
+			return hdr, err
+		}
+	}
+	if len(b.tombs) > 0 {
+		hdr.Flags |= flagDelta
+		if err := b.addSection(secTombs, encodeTombstones(b.tombs, uint64(len(b.indices)))); err != nil {
+			return hdr, err
+		}
+	}
+
+	if b.expires != nil {
+		if err := b.addSection(secExpiry, b.encodeExpiry()); err != nil {
+			return hdr, err
+		}
+	}
+
+	if len(b.spaces) > 0 {
+		if err := b.addSection(secSpaces, encodeSpaces(b.spaces)); err != nil {
+			return hdr, err
+		}
+	}
+	if err := b.addSection(secStats, encodeStats(b.stats)); err != nil {
+		return hdr, err
+	}
+
+	// Хэш покрывает всё от конца заголовка до секции хэша, поэтому одинаков
+	// для файлов с заголовком в начале и в конце. У пространств имён своего
+	// хэша нет: их содержимое входит в хэш общего файла.
+	if !b.nested {
+		if err := b.align(); err != nil {
+			return hdr, err
+		}
+		if err := b.addSection(secHash, b.hash.Sum(nil)); err != nil {
+			return hdr, err
+		}
+	}
+
+	// Таблица секций пишется сразу за секциями.
+	if len(b.sections) > 0 {
+		hdr.OffSections = b.off
+		hdr.NumSections = uint32(len(b.sections))
+		secBuf := make([]byte, sectionEntrySize)
+		for _, sec := range b.sections {
+			binary.LittleEndian.PutUint32(secBuf[0:4], sec.kind)
+			binary.LittleEndian.PutUint64(secBuf[8:16], sec.off)
+			binary.LittleEndian.PutUint64(secBuf[16:24], sec.size)
+			if err := b.write(secBuf); err != nil {
+				return hdr, err
+			}
+		}
+	}
+	return hdr, nil
+}
+
+// writeIndex записывает записи индекса в обычном или широком формате.
+func writeIndex(write func(p []byte) error, indices []indexEntry, wide bool) error {
+	if !wide {
+		recBuf := make([]byte, indexEntrySize)
+		for _, it := range indices {
+			binary.LittleEndian.PutUint64(recBuf[0:8], it.koff)
+			binary.LittleEndian.PutUint32(recBuf[8:12], it.klen)
+			binary.LittleEndian.PutUint64(recBuf[12:20], it.voff)
+			binary.LittleEndian.PutUint32(recBuf[20:24], uint32(it.vlen))
+			if err := write(recBuf); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	recBuf := make([]byte, wideEntrySize)
+	for _, it := range indices {
+		var flags uint32
+		if it.raw {
+			flags |= entryRaw
+		}
+		binary.LittleEndian.PutUint64(recBuf[0:8], it.koff)
+		binary.LittleEndian.PutUint32(recBuf[8:12], it.klen)
+		binary.LittleEndian.PutUint32(recBuf[12:16], flags)
+		binary.LittleEndian.PutUint64(recBuf[16:24], it.voff)
+		binary.LittleEndian.PutUint64(recBuf[24:32], it.vlen)
+		if err := write(recBuf); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// valueBytes приводит значение из дерева к []byte.
+func valueBytes(v any) []byte {
+	switch vv := v.(type) {
+	case []byte:
+		return vv
+	case string:
+		return []byte(vv)
+	default:
+		return []byte(fmt.Sprint(vv))
+	}
+}
+
+// Build — обёртка над BuildWithOptions с параметрами по умолчанию.
+func Build(tree art.Tree, path string) error {
+	return BuildWithOptions(tree, path, BuildOptions{Compression: 0, ZstdLevel: 1, SizeCutover: 256})
+}
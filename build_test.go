@@ -0,0 +1,216 @@
+package qwick
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	art "github.com/plar/go-adaptive-radix-tree/v2"
+)
+
+func TestBuildTo(t *testing.T) {
+	tmpDir, _ := os.MkdirTemp("", "qwick_buildto")
+	defer os.RemoveAll(tmpDir)
+
+	tree := jsonTree(500)
+	for _, opts := range []BuildOptions{
+		{Compression: 0, SizeCutover: 128},
+		{Layout: LayoutBlocks},
+	} {
+		var buf bytes.Buffer
+		if err := BuildTo(&buf, tree, opts); err != nil {
+			t.Fatalf("Ошибка BuildTo: %v", err)
+		}
+
+		db, err := OpenBytes(buf.Bytes())
+		if err != nil {
+			t.Fatalf("Ошибка OpenBytes: %v", err)
+		}
+		tree.ForEach(func(n art.Node) bool {
+			val, ok, err := db.Find(n.Key(), nil)
+			if !ok || err != nil || string(val) != string(n.Value().([]byte)) {
+				t.Fatalf("Ошибка Find %s: ok %v, err %v", n.Key(), ok, err)
+			}
+			return true
+		})
+		db.Close()
+
+		// Файл с заголовком в начале отличается от потокового только заголовком.
+		dbPath := filepath.Join(tmpDir, "header.qwick")
+		if err := BuildWithOptions(tree, dbPath, opts); err != nil {
+			t.Fatalf("Ошибка BuildWithOptions: %v", err)
+		}
+		data, _ := os.ReadFile(dbPath)
+		streamed := buf.Bytes()
+		if !bytes.Equal(data[headerSize:], streamed[headerSize:len(streamed)-headerSize]) {
+			t.Error("Тело потокового файла отличается от файла с заголовком в начале")
+		}
+
+		// Потоковый файл открывается и через Open.
+		streamPath := filepath.Join(tmpDir, "footer.qwick")
+		os.WriteFile(streamPath, streamed, 0644)
+		fdb, err := Open(streamPath)
+		if err != nil {
+			t.Fatalf("Ошибка Open потокового файла: %v", err)
+		}
+		if fdb.num != 500 {
+			t.Errorf("Неверное число записей: %d", fdb.num)
+		}
+		fdb.Close()
+	}
+}
+
+func TestBuildToTruncated(t *testing.T) {
+	tree := New()
+	tree.Insert([]byte("k"), []byte("v"))
+
+	var buf bytes.Buffer
+	if err := BuildTo(&buf, tree, BuildOptions{}); err != nil {
+		t.Fatalf("Ошибка BuildTo: %v", err)
+	}
+	// Без footer-заголовка файл не открывается.
+	if _, err := OpenBytes(buf.Bytes()[:buf.Len()-headerSize]); err == nil {
+		t.Error("Ожидалась ошибка для обрезанного потокового файла")
+	}
+}
+
+func TestDeterministicBuild(t *testing.T) {
+	tmpDir, _ := os.MkdirTemp("", "qwick_determ")
+	defer os.RemoveAll(tmpDir)
+
+	tree := jsonTree(1000)
+	tree.Insert([]byte("user:000007"), Tombstone)
+	for _, opts := range []BuildOptions{
+		{ZstdLevel: 2, SizeCutover: 128, Deterministic: true},
+		{Compression: compZstd, ZstdLevel: 3, Deterministic: true},
+		{Layout: LayoutBlocks, BlockSize: 4096, Deterministic: true},
+	} {
+		var files [2][]byte
+		for i := range files {
+			path := filepath.Join(tmpDir, fmt.Sprintf("det%d.qwick", i))
+			if err := BuildWithOptions(tree, path, opts); err != nil {
+				t.Fatalf("Ошибка BuildWithOptions: %v", err)
+			}
+			files[i], _ = os.ReadFile(path)
+		}
+		if !bytes.Equal(files[0], files[1]) {
+			t.Fatalf("Повторная сборка дала другой файл (%+v)", opts)
+		}
+
+		db, err := OpenBytes(files[0])
+		if err != nil {
+			t.Fatalf("Ошибка OpenBytes: %v", err)
+		}
+		if db.hdr.OffIndex%deterministicAlign != 0 || db.hdr.OffSections%deterministicAlign != 0 {
+			t.Errorf("Индекс или секции не выровнены: %d, %d", db.hdr.OffIndex, db.hdr.OffSections)
+		}
+		for _, s := range db.sections {
+			if s.off%deterministicAlign != 0 {
+				t.Errorf("Секция %d не выровнена: %d", s.kind, s.off)
+			}
+		}
+		sum := sha256.Sum256(files[0][headerSize:db.sections[len(db.sections)-1].off])
+		if h := db.ContentHash(); !bytes.Equal(h, sum[:]) {
+			t.Errorf("ContentHash %x, ожидалось %x", h, sum)
+		}
+
+		// Потоковая сборка даёт тот же хэш содержимого.
+		var buf bytes.Buffer
+		if err := BuildTo(&buf, tree, opts); err != nil {
+			t.Fatalf("Ошибка BuildTo: %v", err)
+		}
+		streamed, err := OpenBytes(buf.Bytes())
+		if err != nil {
+			t.Fatalf("Ошибка OpenBytes: %v", err)
+		}
+		if !bytes.Equal(streamed.ContentHash(), db.ContentHash()) {
+			t.Error("Хэш потокового файла отличается")
+		}
+		streamed.Close()
+		db.Close()
+	}
+
+	err := BuildWithOptions(tree, filepath.Join(tmpDir, "dict.qwick"), BuildOptions{
+		Deterministic:   true,
+		TrainDictionary: DictOptions{DictSize: 16 << 10},
+	})
+	if err == nil {
+		t.Error("Ожидалась ошибка обучения словаря в детерминированном режиме")
+	}
+}
+
+func TestDedupe(t *testing.T) {
+	dir := t.TempDir()
+	plans := []string{`{"plan":"free","limit":10}`, `{"plan":"pro","limit":1000}`, `{"plan":"team","limit":100000}`}
+	tree := New()
+	for i := range 3000 {
+		tree.Insert([]byte(fmt.Sprintf("user%05d", i)), []byte(plans[i%len(plans)]+strings.Repeat(" ", 200)))
+	}
+
+	for _, opts := range []BuildOptions{
+		{Compression: compZstd},
+		{Compression: 0, SizeCutover: 64},
+		{},
+	} {
+		plain := filepath.Join(dir, "plain.qwick")
+		deduped := filepath.Join(dir, "dedupe.qwick")
+		if err := BuildWithOptions(tree, plain, opts); err != nil {
+			t.Fatal(err)
+		}
+		opts.Dedupe = true
+		if err := BuildWithOptions(tree, deduped, opts); err != nil {
+			t.Fatal(err)
+		}
+		ps, _ := os.Stat(plain)
+		ds, _ := os.Stat(deduped)
+		if ds.Size() >= ps.Size() {
+			t.Errorf("%+v: размер с Dedupe %d не меньше %d", opts, ds.Size(), ps.Size())
+		}
+
+		db, err := Open(deduped)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := db.Verify(); err != nil {
+			t.Errorf("Verify: %v", err)
+		}
+		a, _ := db.readIndex(0)
+		b, _ := db.readIndex(3)
+		if a.voff != b.voff || a.vlen != b.vlen {
+			t.Errorf("одинаковые значения не разделяют участок: %+v %+v", a, b)
+		}
+		for _, i := range []int{0, 1, 2, 1234, 2999} {
+			v, ok, err := db.Find([]byte(fmt.Sprintf("user%05d", i)), nil)
+			if err != nil || !ok || !strings.HasPrefix(string(v), plans[i%len(plans)]) {
+				t.Errorf("Find(%d) = %q, %v, %v", i, v, ok, err)
+			}
+		}
+
+		// Слияние копирует значения как есть и тоже может их разделять.
+		merged := filepath.Join(dir, "merged.qwick")
+		if err := MergeWithOptions(merged, []*MMAPDB{db}, LastWins, opts); err != nil {
+			t.Fatal(err)
+		}
+		m, err := Open(merged)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := m.Verify(); err != nil {
+			t.Errorf("Verify после слияния: %v", err)
+		}
+		if m.num != db.num {
+			t.Errorf("после слияния %d записей, ожидалось %d", m.num, db.num)
+		}
+		m.Close()
+		db.Close()
+	}
+
+	err := BuildWithOptions(tree, filepath.Join(dir, "blocks.qwick"), BuildOptions{Layout: LayoutBlocks, Dedupe: true})
+	if err == nil {
+		t.Error("Dedupe с блочной раскладкой должен вернуть ошибку")
+	}
+}
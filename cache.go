@@ -0,0 +1,138 @@
+package qwick
+
+import (
+	"container/list"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// OpenOptions управляет настройками чтения базы.
+type OpenOptions struct {
+	// CacheBytes - бюджет памяти кэша распакованных значений для Find (0 = кэш выключен).
+	// Для несжатых файлов и блочной раскладки кэш не создаётся.
+	CacheBytes int64
+
+	// Now - источник текущего времени для проверки сроков действия записей
+	// (nil = time.Now). Позволяет тестам подменять часы.
+	Now func() time.Time
+
+	// Observer получает события чтения (поиск, обход, распаковка). nil - без накладных расходов.
+	Observer Observer
+
+	// Настройки страничного кэша действуют только для файлов, отображённых в память
+	// (Open, OpenWithOptions). Advice - подсказка ядру о характере доступа (madvise),
+	// LockIndex закрепляет индекс в памяти (mlock), SequentialScan - число записей,
+	// после которого обход помечает оставшуюся часть файла как последовательную.
+	Advice         Advice
+	LockIndex      bool
+	SequentialScan int
+}
+
+// CacheStats - счётчики кэша распакованных значений.
+type CacheStats struct {
+	Hits    uint64
+	Misses  uint64
+	Entries int
+	Bytes   int64
+}
+
+const (
+	cacheShards    = 16
+	cacheEntryCost = 64 // примерные накладные расходы на запись кэша
+)
+
+// valueCache - шардированный LRU-кэш распакованных значений по номеру записи индекса.
+type valueCache struct {
+	shards [cacheShards]cacheShard
+	hits   atomic.Uint64
+	misses atomic.Uint64
+}
+
+type cacheShard struct {
+	mu     sync.Mutex
+	budget int64
+	used   int64
+	items  map[uint64]*list.Element
+	lru    list.List
+}
+
+type cacheItem struct {
+	idx uint64
+	val []byte
+}
+
+func newValueCache(budget int64) *valueCache {
+	c := &valueCache{}
+	for i := range c.shards {
+		c.shards[i].budget = budget / cacheShards
+		c.shards[i].items = make(map[uint64]*list.Element)
+	}
+	return c
+}
+
+func (c *valueCache) shard(idx uint64) *cacheShard {
+	return &c.shards[(idx*0x9E3779B97F4A7C15)>>60]
+}
+
+// get возвращает значение из кэша. Срез разделяется между читателями и не должен изменяться.
+func (c *valueCache) get(idx uint64) ([]byte, bool) {
+	s := c.shard(idx)
+	s.mu.Lock()
+	el, ok := s.items[idx]
+	if ok {
+		s.lru.MoveToFront(el)
+	}
+	s.mu.Unlock()
+	if !ok {
+		c.misses.Add(1)
+		return nil, false
+	}
+	c.hits.Add(1)
+	return el.Value.(*cacheItem).val, true
+}
+
+// put сохраняет копию значения, вытесняя самые старые записи шарда.
+func (c *valueCache) put(idx uint64, val []byte) {
+	cost := int64(len(val)) + cacheEntryCost
+	s := c.shard(idx)
+	if cost > s.budget {
+		return
+	}
+	cp := append([]byte(nil), val...)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.items[idx]; ok {
+		return
+	}
+	for s.used+cost > s.budget {
+		el := s.lru.Back()
+		it := el.Value.(*cacheItem)
+		s.lru.Remove(el)
+		delete(s.items, it.idx)
+		s.used -= int64(len(it.val)) + cacheEntryCost
+	}
+	s.items[idx] = s.lru.PushFront(&cacheItem{idx: idx, val: cp})
+	s.used += cost
+}
+
+func (c *valueCache) stats() CacheStats {
+	st := CacheStats{Hits: c.hits.Load(), Misses: c.misses.Load()}
+	for i := range c.shards {
+		s := &c.shards[i]
+		s.mu.Lock()
+		st.Entries += len(s.items)
+		st.Bytes += s.used
+		s.mu.Unlock()
+	}
+	return st
+}
+
+// CacheStats возвращает счётчики кэша значений. Без кэша возвращаются нули.
+func (db *MMAPDB) CacheStats() CacheStats {
+	if db.vcache == nil {
+		return CacheStats{}
+	}
+	return db.vcache.stats()
+}
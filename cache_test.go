@@ -0,0 +1,86 @@
+package qwick
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+)
+
+func TestValueCache(t *testing.T) {
+	tmpDir, _ := os.MkdirTemp("", "qwick_cache")
+	defer os.RemoveAll(tmpDir)
+	dbPath := filepath.Join(tmpDir, "cache.qwick")
+
+	tree := New()
+	for i := 0; i < 100; i++ {
+		tree.Insert([]byte(fmt.Sprintf("k%03d", i)), bytes.Repeat([]byte{byte(i)}, 300))
+	}
+	if err := BuildWithOptions(tree, dbPath, BuildOptions{Compression: compZstd}); err != nil {
+		t.Fatalf("Ошибка BuildWithOptions: %v", err)
+	}
+
+	db, err := OpenWithOptions(dbPath, OpenOptions{CacheBytes: 1 << 20})
+	if err != nil {
+		t.Fatalf("Ошибка Open: %v", err)
+	}
+	defer db.Close()
+
+	var wg sync.WaitGroup
+	for g := 0; g < 8; g++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 100; i++ {
+				val, ok, err := db.Find([]byte(fmt.Sprintf("k%03d", i)), nil)
+				if !ok || err != nil || !bytes.Equal(val, bytes.Repeat([]byte{byte(i)}, 300)) {
+					t.Errorf("Ошибка Find k%03d: ok %v, err %v", i, ok, err)
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	st := db.CacheStats()
+	if st.Hits+st.Misses != 800 || st.Hits == 0 || st.Entries != 100 {
+		t.Errorf("Неверная статистика кэша: %+v", st)
+	}
+
+	// Небольшой бюджет - кэш не превышает его.
+	small, _ := OpenWithOptions(dbPath, OpenOptions{CacheBytes: cacheShards * 1024})
+	defer small.Close()
+	for i := 0; i < 100; i++ {
+		small.Find([]byte(fmt.Sprintf("k%03d", i)), nil)
+	}
+	if st := small.CacheStats(); st.Bytes > cacheShards*1024 {
+		t.Errorf("Превышен бюджет кэша: %+v", st)
+	}
+}
+
+func TestValueCacheBypass(t *testing.T) {
+	tmpDir, _ := os.MkdirTemp("", "qwick_cache_bypass")
+	defer os.RemoveAll(tmpDir)
+	dbPath := filepath.Join(tmpDir, "raw.qwick")
+
+	tree := New()
+	tree.Insert([]byte("k"), []byte("v"))
+	if err := BuildWithOptions(tree, dbPath, BuildOptions{Compression: compNone}); err != nil {
+		t.Fatalf("Ошибка BuildWithOptions: %v", err)
+	}
+
+	db, err := OpenWithOptions(dbPath, OpenOptions{CacheBytes: 1 << 20})
+	if err != nil {
+		t.Fatalf("Ошибка Open: %v", err)
+	}
+	defer db.Close()
+
+	if db.vcache != nil {
+		t.Error("Кэш не должен создаваться для несжатого файла")
+	}
+	if val, ok, _ := db.Find([]byte("k"), nil); !ok || string(val) != "v" {
+		t.Errorf("Ошибка Find: %q", val)
+	}
+}
@@ -0,0 +1,197 @@
+// Команда qwick - утилиты для работы с файлами qwick.
+//
+//	qwick diff [-q] [-o delta.qwick] old.qwick new.qwick
+//	qwick stats [-full] file.qwick
+package main
+
+import (
+	"encoding/hex"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"unicode"
+
+	"github.com/globalmac/qwick"
+)
+
+const usage = `Использование:
+  qwick diff [-q] [-o delta.qwick] old.qwick new.qwick
+  qwick stats [-full] file.qwick
+`
+
+func main() {
+	if err := run(os.Args[1:], os.Stdout); err != nil {
+		fmt.Fprintln(os.Stderr, "qwick:", err)
+		os.Exit(1)
+	}
+}
+
+func run(args []string, stdout io.Writer) error {
+	if len(args) == 0 {
+		return errors.New("не указана команда\n" + usage)
+	}
+	switch args[0] {
+	case "diff":
+		return runDiff(args[1:], stdout)
+	case "stats":
+		return runStats(args[1:], stdout)
+	}
+	return fmt.Errorf("неизвестная команда %q\n%s", args[0], usage)
+}
+
+func runDiff(args []string, stdout io.Writer) error {
+	fs := flag.NewFlagSet("diff", flag.ContinueOnError)
+	quiet := fs.Bool("q", false, "печатать только итог")
+	out := fs.String("o", "", "записать изменения в дельта-файл")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 2 {
+		return errors.New("diff: нужны два файла\n" + usage)
+	}
+
+	a, err := qwick.Open(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	defer a.Close()
+	b, err := qwick.Open(fs.Arg(1))
+	if err != nil {
+		return err
+	}
+	defer b.Close()
+
+	var (
+		counts       [4]int
+		grow, shrink int64
+	)
+	err = qwick.Diff(a, b, func(c qwick.Change) bool {
+		counts[c.Kind]++
+		if d := c.Delta(); d > 0 {
+			grow += d
+		} else {
+			shrink -= d
+		}
+		if *quiet {
+			return true
+		}
+		switch c.Kind {
+		case qwick.Added:
+			fmt.Fprintf(stdout, "+ %q %d\n", c.Key, len(c.New))
+		case qwick.Removed:
+			fmt.Fprintf(stdout, "- %q %d\n", c.Key, len(c.Old))
+		case qwick.Modified:
+			fmt.Fprintf(stdout, "~ %q %d -> %d\n", c.Key, len(c.Old), len(c.New))
+		}
+		return true
+	})
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(stdout, "added %d, removed %d, modified %d, bytes +%d -%d\n",
+		counts[qwick.Added], counts[qwick.Removed], counts[qwick.Modified], grow, shrink)
+
+	if *out != "" {
+		return qwick.BuildDelta(a, b, *out)
+	}
+	return nil
+}
+
+func runStats(args []string, stdout io.Writer) error {
+	fs := flag.NewFlagSet("stats", flag.ContinueOnError)
+	full := fs.Bool("full", false, "обойти все записи и построить гистограммы размеров")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		return errors.New("stats: нужен один файл\n" + usage)
+	}
+
+	path := fs.Arg(0)
+	fi, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+	db, err := qwick.Open(path)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	fmt.Fprintf(stdout, "file: %s\n", path)
+	fmt.Fprintf(stdout, "size: %d\n", fi.Size())
+	if h := db.ContentHash(); h != nil {
+		fmt.Fprintf(stdout, "content hash: %x\n", h)
+	}
+
+	st, err := db.Stats(*full)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(stdout, "entries: %d (tombstones %d)\n", st.Entries, st.Tombstones)
+	fmt.Fprintf(stdout, "keys: %d bytes, max %d\n", st.KeyBytes, st.MaxKey)
+	fmt.Fprintf(stdout, "values: %d bytes, max %d, stored %d\n", st.ValueBytes, st.MaxValue, st.StoredBytes)
+	if r := st.Ratio(); r > 0 {
+		fmt.Fprintf(stdout, "ratio: %.2f\n", r)
+	}
+	fmt.Fprintf(stdout, "index: %d bytes\n", st.IndexBytes)
+	fmt.Fprintf(stdout, "codecs: zstd %d, s2 %d, plain %d, shared %d, copied %d\n",
+		st.Zstd, st.S2, st.Plain, st.Shared, st.Copied)
+	for _, c := range []struct {
+		name  string
+		bytes qwick.CodecBytes
+	}{{"zstd", st.ZstdBytes}, {"s2", st.S2Bytes}, {"plain", st.PlainBytes}} {
+		if c.bytes.Stored > 0 {
+			fmt.Fprintf(stdout, "  %s: %d -> %d bytes, ratio %.2f\n", c.name, c.bytes.Raw, c.bytes.Stored, c.bytes.Ratio())
+		}
+	}
+	if *full {
+		printHistogram(stdout, "key sizes", &st.KeySizes)
+		printHistogram(stdout, "value sizes", &st.ValueSizes)
+	}
+
+	meta := db.Metadata()
+	if len(meta) == 0 {
+		return nil
+	}
+	keys := make([]string, 0, len(meta))
+	for k := range meta {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	fmt.Fprintln(stdout, "metadata:")
+	for _, k := range keys {
+		fmt.Fprintf(stdout, "  %s: %s\n", k, metaValue(meta[k]))
+	}
+	return nil
+}
+
+// printHistogram печатает непустые корзины гистограммы как диапазоны размеров.
+func printHistogram(w io.Writer, name string, h *qwick.Histogram) {
+	fmt.Fprintf(w, "%s:\n", name)
+	for i, n := range h {
+		if n == 0 {
+			continue
+		}
+		if i == 0 {
+			fmt.Fprintf(w, "  0: %d\n", n)
+			continue
+		}
+		fmt.Fprintf(w, "  %d-%d: %d\n", uint64(1)<<(i-1), uint64(1)<<(i-1)*2-1, n)
+	}
+}
+
+// metaValue печатает значение метаданных как текст, а двоичные данные - в hex.
+func metaValue(v []byte) string {
+	s := string(v)
+	for _, r := range s {
+		if r == unicode.ReplacementChar || !strconv.IsPrint(r) {
+			return "0x" + hex.EncodeToString(v)
+		}
+	}
+	return s
+}
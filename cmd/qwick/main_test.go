@@ -0,0 +1,106 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/globalmac/qwick"
+)
+
+func buildFile(t *testing.T, path string, kv map[string]string) {
+	t.Helper()
+	tree := qwick.New()
+	for k, v := range kv {
+		tree.Insert([]byte(k), []byte(v))
+	}
+	if err := qwick.Build(tree, path); err != nil {
+		t.Fatalf("Ошибка Build: %v", err)
+	}
+}
+
+func TestDiff(t *testing.T) {
+	tmpDir := t.TempDir()
+	oldPath := filepath.Join(tmpDir, "old.qwick")
+	newPath := filepath.Join(tmpDir, "new.qwick")
+	deltaPath := filepath.Join(tmpDir, "delta.qwick")
+	buildFile(t, oldPath, map[string]string{"a": "1", "b": "2"})
+	buildFile(t, newPath, map[string]string{"b": "22", "c": "3"})
+
+	var out bytes.Buffer
+	if err := run([]string{"diff", "-o", deltaPath, oldPath, newPath}, &out); err != nil {
+		t.Fatalf("Ошибка diff: %v", err)
+	}
+	want := "- \"a\" 1\n~ \"b\" 1 -> 2\n+ \"c\" 1\nadded 1, removed 1, modified 1, bytes +2 -1\n"
+	if out.String() != want {
+		t.Errorf("Вывод diff:\n%s\nожидалось:\n%s", out.String(), want)
+	}
+	if _, err := os.Stat(deltaPath); err != nil {
+		t.Errorf("Дельта-файл не создан: %v", err)
+	}
+
+	out.Reset()
+	if err := run([]string{"diff", "-q", oldPath, newPath}, &out); err != nil || strings.Count(out.String(), "\n") != 1 {
+		t.Errorf("diff -q: %q, err %v", out.String(), err)
+	}
+}
+
+func TestStats(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "meta.qwick")
+	tree := qwick.New()
+	tree.Insert([]byte("k"), []byte("v"))
+	err := qwick.BuildWithOptions(tree, path, qwick.BuildOptions{Metadata: map[string][]byte{
+		"team":   []byte("search"),
+		"schema": {0, 1},
+	}})
+	if err != nil {
+		t.Fatalf("Ошибка BuildWithOptions: %v", err)
+	}
+
+	var out bytes.Buffer
+	if err := run([]string{"stats", path}, &out); err != nil {
+		t.Fatalf("Ошибка stats: %v", err)
+	}
+	for _, want := range []string{"content hash: ", "metadata:\n  schema: 0x0001\n  team: search\n"} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("Вывод stats не содержит %q:\n%s", want, out.String())
+		}
+	}
+}
+
+func TestStatsFull(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "full.qwick")
+	buildFile(t, path, map[string]string{"a": "", "bb": "12", "ccc": "1234567"})
+
+	var out bytes.Buffer
+	if err := run([]string{"stats", "-full", path}, &out); err != nil {
+		t.Fatalf("Ошибка stats: %v", err)
+	}
+	for _, want := range []string{
+		"entries: 3 (tombstones 0)\n",
+		"keys: 6 bytes, max 3\n",
+		"values: 9 bytes, max 7",
+		"  s2: 9 -> 14 bytes, ratio 0.64\n",
+		"key sizes:\n  1-1: 1\n  2-3: 2\n",
+		"value sizes:\n  0: 1\n  2-3: 1\n  4-7: 1\n",
+	} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("Вывод stats не содержит %q:\n%s", want, out.String())
+		}
+	}
+}
+
+func TestUsage(t *testing.T) {
+	var out bytes.Buffer
+	if err := run(nil, &out); err == nil {
+		t.Error("Ожидалась ошибка без команды")
+	}
+	if err := run([]string{"nope"}, &out); err == nil {
+		t.Error("Ожидалась ошибка для неизвестной команды")
+	}
+	if err := run([]string{"diff", "one.qwick"}, &out); err == nil {
+		t.Error("Ожидалась ошибка для одного файла")
+	}
+}
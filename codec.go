@@ -0,0 +1,169 @@
+package qwick
+
+import (
+	"bytes"
+	"encoding"
+	"encoding/binary"
+	"encoding/gob"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/globalmac/qwick/keyenc"
+)
+
+// Codec преобразует значения типа T в байты и обратно.
+type Codec[T any] interface {
+	Encode(v T) ([]byte, error)
+	Decode(data []byte) (T, error)
+}
+
+// JSONCodec кодирует значения в JSON.
+type JSONCodec[T any] struct{}
+
+func (JSONCodec[T]) Encode(v T) ([]byte, error) { return json.Marshal(v) }
+
+func (JSONCodec[T]) Decode(data []byte) (T, error) {
+	var v T
+	err := json.Unmarshal(data, &v)
+	return v, err
+}
+
+// GobCodec кодирует значения через encoding/gob. Каждое значение хранит своё
+// описание типа, поэтому кодек удобен для сложных структур, но не компактен.
+type GobCodec[T any] struct{}
+
+func (GobCodec[T]) Encode(v T) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(v); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (GobCodec[T]) Decode(data []byte) (T, error) {
+	var v T
+	err := gob.NewDecoder(bytes.NewReader(data)).Decode(&v)
+	return v, err
+}
+
+// FixedCodec - двоичный кодек для типов фиксированного размера (числа, массивы и
+// структуры из них), кодируемых encoding/binary (little-endian) без описания типа.
+// Строки, срезы и map не поддерживаются - для них есть MsgpackCodec. Типы, реализующие
+// encoding.BinaryMarshaler и encoding.BinaryUnmarshaler (по указателю), кодируются
+// своими методами.
+type FixedCodec[T any] struct{}
+
+func (FixedCodec[T]) Encode(v T) ([]byte, error) {
+	if m, ok := any(v).(encoding.BinaryMarshaler); ok {
+		return m.MarshalBinary()
+	}
+	return binary.Append(nil, binary.LittleEndian, v)
+}
+
+func (FixedCodec[T]) Decode(data []byte) (T, error) {
+	var v T
+	if u, ok := any(&v).(encoding.BinaryUnmarshaler); ok {
+		return v, u.UnmarshalBinary(data)
+	}
+	n, err := binary.Decode(data, binary.LittleEndian, &v)
+	if err == nil && n != len(data) {
+		err = fmt.Errorf("лишние %d байт после значения", len(data)-n)
+	}
+	return v, err
+}
+
+// RawCodec хранит []byte как есть. Decode возвращает копию.
+type RawCodec struct{}
+
+func (RawCodec) Encode(v []byte) ([]byte, error) { return v, nil }
+
+func (RawCodec) Decode(data []byte) ([]byte, error) { return append([]byte{}, data...), nil }
+
+// StringCodec хранит строку как есть.
+type StringCodec struct{}
+
+func (StringCodec) Encode(v string) ([]byte, error) { return []byte(v), nil }
+
+func (StringCodec) Decode(data []byte) (string, error) { return string(data), nil }
+
+// KeyCodec кодирует ключи так, что порядок байт совпадает с естественным порядком
+// значений, поэтому Range и Prefix по закодированным ключам работают как ожидается.
+// DecodeKey возвращает число прочитанных байт, что позволяет составлять ключи из частей.
+// Готовые кодеки построены на пакете keyenc.
+type KeyCodec[K any] interface {
+	AppendKey(dst []byte, k K) []byte
+	DecodeKey(b []byte) (k K, n int, err error)
+}
+
+// Uint64Key кодирует uint64 (keyenc.AppendUint64).
+type Uint64Key struct{}
+
+func (Uint64Key) AppendKey(dst []byte, k uint64) []byte   { return keyenc.AppendUint64(dst, k) }
+func (Uint64Key) DecodeKey(b []byte) (uint64, int, error) { return keyenc.DecodeUint64(b) }
+
+// Int64Key кодирует int64 (keyenc.AppendInt64).
+type Int64Key struct{}
+
+func (Int64Key) AppendKey(dst []byte, k int64) []byte   { return keyenc.AppendInt64(dst, k) }
+func (Int64Key) DecodeKey(b []byte) (int64, int, error) { return keyenc.DecodeInt64(b) }
+
+// Float64Key кодирует float64 (keyenc.AppendFloat64).
+type Float64Key struct{}
+
+func (Float64Key) AppendKey(dst []byte, k float64) []byte   { return keyenc.AppendFloat64(dst, k) }
+func (Float64Key) DecodeKey(b []byte) (float64, int, error) { return keyenc.DecodeFloat64(b) }
+
+// TimeKey кодирует time.Time (keyenc.AppendTime); при чтении время возвращается в UTC.
+type TimeKey struct{}
+
+func (TimeKey) AppendKey(dst []byte, k time.Time) []byte   { return keyenc.AppendTime(dst, k) }
+func (TimeKey) DecodeKey(b []byte) (time.Time, int, error) { return keyenc.DecodeTime(b) }
+
+// StringKey хранит строку как есть и занимает ключ до конца. В составном ключе
+// может быть только последней частью; для остальных позиций есть EscapedStringKey.
+type StringKey struct{}
+
+func (StringKey) AppendKey(dst []byte, k string) []byte   { return append(dst, k...) }
+func (StringKey) DecodeKey(b []byte) (string, int, error) { return string(b), len(b), nil }
+
+// EscapedStringKey кодирует строку с экранированием и терминатором (keyenc.AppendString),
+// сохраняя порядок в составе составного ключа.
+type EscapedStringKey struct{}
+
+func (EscapedStringKey) AppendKey(dst []byte, k string) []byte   { return keyenc.AppendString(dst, k) }
+func (EscapedStringKey) DecodeKey(b []byte) (string, int, error) { return keyenc.DecodeString(b) }
+
+// Pair - составной ключ из двух частей.
+type Pair[A, B any] struct {
+	First  A
+	Second B
+}
+
+// PairKey возвращает кодек составного ключа. Порядок определяется сначала первой
+// частью, затем второй; первая часть должна быть самоограниченной (не StringKey).
+func PairKey[A, B any](a KeyCodec[A], b KeyCodec[B]) KeyCodec[Pair[A, B]] {
+	return pairKey[A, B]{a, b}
+}
+
+type pairKey[A, B any] struct {
+	a KeyCodec[A]
+	b KeyCodec[B]
+}
+
+func (p pairKey[A, B]) AppendKey(dst []byte, k Pair[A, B]) []byte {
+	return p.b.AppendKey(p.a.AppendKey(dst, k.First), k.Second)
+}
+
+func (p pairKey[A, B]) DecodeKey(b []byte) (Pair[A, B], int, error) {
+	var k Pair[A, B]
+	first, n, err := p.a.DecodeKey(b)
+	if err != nil {
+		return k, 0, err
+	}
+	second, m, err := p.b.DecodeKey(b[n:])
+	if err != nil {
+		return k, 0, err
+	}
+	return Pair[A, B]{first, second}, n + m, nil
+}
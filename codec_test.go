@@ -0,0 +1,160 @@
+package qwick
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/globalmac/qwick/keyenc"
+)
+
+func TestValueCodecs(t *testing.T) {
+	type address struct {
+		City string
+		Zip  *int
+	}
+	type user struct {
+		Name    string
+		Age     int
+		Tags    []string
+		Scores  []float64
+		Avatar  []byte
+		Home    address
+		Extra   map[string]int
+		Created time.Time
+	}
+	zip := 101000
+	u := user{
+		Name:    "Иван",
+		Age:     -42,
+		Tags:    []string{"admin", "", "ops"},
+		Scores:  []float64{1.5, -2},
+		Avatar:  []byte{0, 1, 2},
+		Home:    address{City: "Москва", Zip: &zip},
+		Extra:   map[string]int{"b": 2, "a": 1},
+		Created: time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC),
+	}
+
+	for name, c := range map[string]Codec[user]{
+		"json":    JSONCodec[user]{},
+		"gob":     GobCodec[user]{},
+		"msgpack": MsgpackCodec[user]{},
+	} {
+		data, err := c.Encode(u)
+		if err != nil {
+			t.Fatalf("%s: ошибка Encode: %v", name, err)
+		}
+		got, err := c.Decode(data)
+		if err != nil || !reflect.DeepEqual(got, u) {
+			t.Errorf("%s: получено %+v, err %v", name, got, err)
+		}
+	}
+
+	type point struct {
+		X, Y int32
+		Tag  uint8
+	}
+	p := point{X: -5, Y: 7, Tag: 3}
+	data, err := FixedCodec[point]{}.Encode(p)
+	if err != nil || len(data) != 9 {
+		t.Errorf("FixedCodec: ожидалось 9 байт, получено %d, err %v", len(data), err)
+	}
+	if got, err := (FixedCodec[point]{}).Decode(data); err != nil || got != p {
+		t.Errorf("FixedCodec: получено %+v, err %v", got, err)
+	}
+	if _, err := (FixedCodec[point]{}).Decode(make([]byte, 10)); err == nil {
+		t.Error("FixedCodec: ожидалась ошибка для лишних байт")
+	}
+	if _, err := (FixedCodec[user]{}).Encode(u); err == nil {
+		t.Error("FixedCodec: ожидалась ошибка для строк и срезов")
+	}
+
+	raw := []byte("raw")
+	got, _ := RawCodec{}.Decode(raw)
+	got[0] = 'X'
+	if string(raw) != "raw" {
+		t.Error("RawCodec.Decode должен возвращать копию")
+	}
+}
+
+// checkKeyOrder проверяет, что порядок закодированных ключей совпадает с порядком keys.
+func checkKeyOrder[K any](t *testing.T, c KeyCodec[K], keys []K, eq func(a, b K) bool) {
+	t.Helper()
+	enc := make([][]byte, len(keys))
+	for i, k := range keys {
+		enc[i] = c.AppendKey(nil, k)
+		got, n, err := c.DecodeKey(enc[i])
+		if err != nil || n != len(enc[i]) || !eq(got, k) {
+			t.Errorf("DecodeKey(%v): получено %v, n %d, err %v", k, got, n, err)
+		}
+	}
+	if !sort.SliceIsSorted(enc, func(i, j int) bool { return bytes.Compare(enc[i], enc[j]) < 0 }) {
+		t.Errorf("Порядок закодированных ключей не совпадает с порядком %v", keys)
+	}
+}
+
+func TestKeyCodecs(t *testing.T) {
+	checkKeyOrder(t, Uint64Key{}, []uint64{0, 1, 255, 256, math.MaxUint64}, func(a, b uint64) bool { return a == b })
+	checkKeyOrder(t, Int64Key{}, []int64{math.MinInt64, -256, -1, 0, 1, math.MaxInt64}, func(a, b int64) bool { return a == b })
+	checkKeyOrder(t, Float64Key{}, []float64{math.Inf(-1), -1.5, -1e-300, 0, 1e-300, 2, math.Inf(1)}, func(a, b float64) bool { return a == b })
+	base := time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC)
+	checkKeyOrder(t, TimeKey{}, []time.Time{base.AddDate(-100, 0, 0), base, base.Add(time.Nanosecond), base.Add(time.Hour)}, time.Time.Equal)
+	checkKeyOrder(t, StringKey{}, []string{"", "a", "ab", "b"}, func(a, b string) bool { return a == b })
+	checkKeyOrder(t, EscapedStringKey{}, []string{"", "\x00", "\x00\x00", "\x00a", "a", "a\x00", "ab", "b"}, func(a, b string) bool { return a == b })
+
+	pk := PairKey[string, int64](EscapedStringKey{}, Int64Key{})
+	checkKeyOrder(t, pk, []Pair[string, int64]{
+		{"a", -1}, {"a", 0}, {"a", 5}, {"a\x00", -9}, {"ab", -100}, {"b", math.MinInt64},
+	}, func(a, b Pair[string, int64]) bool { return a == b })
+
+	if _, _, err := (EscapedStringKey{}).DecodeKey([]byte("abc")); err == nil {
+		t.Error("Ожидалась ошибка для строки без терминатора")
+	}
+	if _, _, err := pk.DecodeKey([]byte("a\x00\x01\x00")); err == nil {
+		t.Error("Ожидалась ошибка для короткой второй части")
+	}
+}
+
+func TestKeyencRange(t *testing.T) {
+	tree := New()
+	for _, id := range []int64{1, 9, 10, 100, -3} {
+		tree.Insert(keyenc.Tuple("order", id), []byte(fmt.Sprint(id)))
+	}
+	tree.Insert(keyenc.Tuple("user", int64(1)), []byte("u1"))
+	var buf bytes.Buffer
+	if err := BuildTo(&buf, tree, BuildOptions{}); err != nil {
+		t.Fatalf("Ошибка BuildTo: %v", err)
+	}
+	db, err := OpenBytes(buf.Bytes())
+	if err != nil {
+		t.Fatalf("Ошибка OpenBytes: %v", err)
+	}
+	defer db.Close()
+
+	var got []string
+	collect := func(k, v []byte) bool {
+		got = append(got, string(v))
+		return true
+	}
+	db.RangeRaw(keyenc.Tuple("order", int64(0)), keyenc.Tuple("order", int64(50)), collect)
+	if fmt.Sprint(got) != "[1 9 10]" {
+		t.Errorf("RangeRaw: %v", got)
+	}
+
+	got = got[:0]
+	prefix := keyenc.Tuple("order")
+	db.RangeRaw(prefix, keyenc.PrefixEnd(prefix), collect)
+	if fmt.Sprint(got) != "[-3 1 9 10 100]" {
+		t.Errorf("RangeRaw по префиксу: %v", got)
+	}
+
+	got = got[:0]
+	db.PrefixRaw(prefix, collect)
+	if fmt.Sprint(got) != "[-3 1 9 10 100]" {
+		t.Errorf("PrefixRaw: %v", got)
+	}
+}
@@ -0,0 +1,87 @@
+package qwick
+
+import (
+	"context"
+	"time"
+)
+
+// ctxCheckEvery - через сколько записей (включая скрытые) обход проверяет отмену контекста.
+const ctxCheckEvery = 256
+
+// ScanOptions ограничивает обход PrefixCtx и RangeCtx.
+type ScanOptions struct {
+	Limit  int           // максимум записей (0 - без ограничения); достижение лимита - не ошибка
+	Budget time.Duration // максимум времени обхода (0 - без ограничения), см. context.WithTimeout
+	Raw    bool          // не распаковывать значения (как PrefixRaw)
+}
+
+// PrefixCtx похож на Prefix, но периодически проверяет ctx и ограничения opts.
+// При отмене контекста или исчерпании Budget возвращает ctx.Err().
+func (db *MMAPDB) PrefixCtx(ctx context.Context, prefix []byte, dst []byte, opts ScanOptions, cb func(key, val []byte) bool) error {
+	op := OpPrefix
+	if opts.Raw {
+		op = OpPrefixRaw
+	}
+	return db.scanCtx(ctx, op, prefix, hasPrefix(prefix), dst, opts, cb)
+}
+
+// RangeCtx похож на Range, но периодически проверяет ctx и ограничения opts.
+func (db *MMAPDB) RangeCtx(ctx context.Context, lo, hi []byte, dst []byte, opts ScanOptions, cb func(key, val []byte) bool) error {
+	op := OpRange
+	if opts.Raw {
+		op = OpRangeRaw
+	}
+	return db.scanCtx(ctx, op, lo, below(hi), dst, opts, cb)
+}
+
+func (db *MMAPDB) scanCtx(ctx context.Context, op Op, lo []byte, inRange func(k []byte) bool, dst []byte, opts ScanOptions, cb func(key, val []byte) bool) error {
+	if opts.Budget > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, opts.Budget)
+		defer cancel()
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if db.obs != nil {
+		var done func()
+		cb, done = db.observeScan(op, cb)
+		defer done()
+	}
+
+	var (
+		n, seen int
+		err     error
+	)
+	// Проверяем контекст по числу пройденных записей, а не выданных: длинная серия
+	// надгробий или истёкших записей тоже должна прерываться.
+	db.scanAll(lo, inRange, func(i uint64, k []byte) bool {
+		seen++
+		if seen%ctxCheckEvery == 0 {
+			if err = ctx.Err(); err != nil {
+				return false
+			}
+		}
+		if db.hidden(i) {
+			return true
+		}
+		var v []byte
+		if opts.Raw {
+			if v = db.getValSlice(i); v == nil {
+				return false
+			}
+		} else {
+			var ok bool
+			v, ok, err = db.valueAt(i, dst)
+			if !ok || err != nil {
+				return false
+			}
+		}
+		n++
+		return cb(k, v) && (opts.Limit <= 0 || n < opts.Limit)
+	})
+	if err == nil {
+		err = db.readErr()
+	}
+	return err
+}
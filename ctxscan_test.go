@@ -0,0 +1,104 @@
+package qwick
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestScanCtx(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "ctx.qwick")
+	if err := BuildWithOptions(jsonTree(2000), path, BuildOptions{Compression: compZstd}); err != nil {
+		t.Fatal(err)
+	}
+	db, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	bg := context.Background()
+
+	n := 0
+	err = db.PrefixCtx(bg, []byte("user:"), nil, ScanOptions{Limit: 10}, func(k, v []byte) bool { n++; return true })
+	if err != nil || n != 10 {
+		t.Errorf("Limit: %d записей, %v", n, err)
+	}
+
+	n = 0
+	err = db.RangeCtx(bg, []byte("user:000100"), []byte("user:000200"), nil, ScanOptions{Raw: true}, func(k, v []byte) bool {
+		n++
+		return true
+	})
+	if err != nil || n != 100 {
+		t.Errorf("RangeCtx: %d записей, %v", n, err)
+	}
+
+	ctx, cancel := context.WithCancel(bg)
+	n = 0
+	err = db.PrefixCtx(ctx, nil, nil, ScanOptions{}, func(k, v []byte) bool {
+		if n++; n == 300 {
+			cancel()
+		}
+		return true
+	})
+	if !errors.Is(err, context.Canceled) || n >= 2000 {
+		t.Errorf("отмена: %d записей, %v", n, err)
+	}
+	if err := db.PrefixCtx(ctx, nil, nil, ScanOptions{}, func(k, v []byte) bool { return true }); !errors.Is(err, context.Canceled) {
+		t.Errorf("отменённый контекст: %v", err)
+	}
+
+	n = 0
+	err = db.PrefixCtx(bg, nil, nil, ScanOptions{Budget: 5 * time.Millisecond, Raw: true}, func(k, v []byte) bool {
+		n++
+		time.Sleep(50 * time.Microsecond)
+		return true
+	})
+	if !errors.Is(err, context.DeadlineExceeded) || n >= 2000 {
+		t.Errorf("Budget: %d записей, %v", n, err)
+	}
+}
+
+// flipCtx отменяется после первой проверки.
+type flipCtx struct {
+	context.Context
+	checks int
+}
+
+func (c *flipCtx) Err() error {
+	if c.checks++; c.checks > 1 {
+		return context.Canceled
+	}
+	return nil
+}
+
+func TestScanCtxHidden(t *testing.T) {
+	start := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	tree := New()
+	for i := range 3 * ctxCheckEvery {
+		tree.Insert([]byte(fmt.Sprintf("old%04d", i)), WithExpiry([]byte("v"), start))
+	}
+	tree.Insert([]byte("zzz"), []byte("live"))
+	path := filepath.Join(t.TempDir(), "hidden.qwick")
+	if err := Build(tree, path); err != nil {
+		t.Fatal(err)
+	}
+	db, err := OpenWithOptions(path, OpenOptions{Now: func() time.Time { return start.Add(time.Hour) }})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	// Все записи до zzz истекли: отмена должна сработать, не дойдя до видимой записи.
+	n := 0
+	err = db.PrefixCtx(&flipCtx{Context: context.Background()}, nil, nil, ScanOptions{}, func(k, v []byte) bool {
+		n++
+		return true
+	})
+	if !errors.Is(err, context.Canceled) || n != 0 {
+		t.Errorf("отмена среди скрытых записей: %d записей, %v", n, err)
+	}
+}
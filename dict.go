@@ -0,0 +1,66 @@
+package qwick
+
+import (
+	"fmt"
+
+	"github.com/klauspost/compress/dict"
+	"github.com/klauspost/compress/zstd"
+	art "github.com/plar/go-adaptive-radix-tree/v2"
+)
+
+// DictOptions задаёт параметры обучения словаря zstd.
+type DictOptions struct {
+	SampleSize int // число значений в выборке (0 = defaultDictSamples)
+	DictSize   int // максимальный размер словаря в байтах (0 = обучение выключено)
+}
+
+const (
+	defaultDictSamples = 10000
+	dictHashBytes      = 6
+	dictID             = 0x51574B01 // фиксированный ID словаря, чтобы сборка не зависела от случайности
+)
+
+// sampleValues выбирает до n значений дерева с дробным шагом: j-й образец - запись
+// j*total/n, поэтому выборка покрывает все ключи, а не только младшие.
+func sampleValues(tree art.Tree, n int) [][]byte {
+	total := tree.Size()
+	n = min(n, total)
+	samples := make([][]byte, 0, n)
+	i := 0
+	tree.ForEach(func(node art.Node) (cont bool) {
+		if i == len(samples)*total/n {
+			samples = append(samples, valueBytes(node.Value()))
+		}
+		i++
+		return len(samples) < n
+	}, art.TraverseLeaf)
+	return samples
+}
+
+// trainDict обучает словарь zstd на равномерной выборке значений дерева.
+func trainDict(tree art.Tree, opts DictOptions, level zstd.EncoderLevel) (d []byte, err error) {
+	sampleSize := opts.SampleSize
+	if sampleSize <= 0 {
+		sampleSize = defaultDictSamples
+	}
+
+	samples := sampleValues(tree, sampleSize)
+
+	// Построитель словаря паникует на вырожденных выборках (слишком мало повторов).
+	defer func() {
+		if r := recover(); r != nil {
+			d, err = nil, fmt.Errorf("не удалось обучить словарь: недостаточно данных в выборке (%d значений)", len(samples))
+		}
+	}()
+
+	d, err = dict.BuildZstdDict(samples, dict.Options{
+		MaxDictSize: opts.DictSize,
+		HashBytes:   dictHashBytes,
+		ZstdDictID:  dictID,
+		ZstdLevel:   level,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("не удалось обучить словарь: %w", err)
+	}
+	return d, nil
+}
@@ -0,0 +1,122 @@
+package qwick
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	art "github.com/plar/go-adaptive-radix-tree/v2"
+)
+
+func jsonTree(n int) art.Tree {
+	type user struct {
+		ID      int      `json:"id"`
+		Name    string   `json:"name"`
+		Email   string   `json:"email"`
+		Country string   `json:"country"`
+		Plan    string   `json:"plan"`
+		Tags    []string `json:"tags"`
+	}
+	plans := []string{"free", "pro", "enterprise"}
+	countries := []string{"RU", "KZ", "BY", "AM"}
+
+	tree := New()
+	for i := 0; i < n; i++ {
+		bin, _ := json.Marshal(user{
+			ID:      i,
+			Name:    fmt.Sprintf("username_%d", i),
+			Email:   fmt.Sprintf("user%d@example.com", i),
+			Country: countries[i%len(countries)],
+			Plan:    plans[i%len(plans)],
+			Tags:    []string{"go", "db", fmt.Sprintf("tag%d", i%17)},
+		})
+		tree.Insert([]byte(fmt.Sprintf("user:%06d", i)), bin)
+	}
+	return tree
+}
+
+func TestTrainDictionary(t *testing.T) {
+	tmpDir, _ := os.MkdirTemp("", "qwick_dict")
+	defer os.RemoveAll(tmpDir)
+
+	tree := jsonTree(5000)
+	plainPath := filepath.Join(tmpDir, "plain.qwick")
+	dictPath := filepath.Join(tmpDir, "dict.qwick")
+
+	if err := BuildWithOptions(tree, plainPath, BuildOptions{Compression: compZstd}); err != nil {
+		t.Fatalf("Ошибка BuildWithOptions: %v", err)
+	}
+	err := BuildWithOptions(tree, dictPath, BuildOptions{
+		Compression:     0,
+		SizeCutover:     256,
+		TrainDictionary: DictOptions{SampleSize: 1000, DictSize: 16 << 10},
+	})
+	if err != nil {
+		t.Fatalf("Ошибка BuildWithOptions со словарём: %v", err)
+	}
+
+	plainInfo, _ := os.Stat(plainPath)
+	dictInfo, _ := os.Stat(dictPath)
+	if dictInfo.Size() >= plainInfo.Size() {
+		t.Errorf("Словарь не уменьшил размер: %d >= %d", dictInfo.Size(), plainInfo.Size())
+	}
+
+	db, err := Open(dictPath)
+	if err != nil {
+		t.Fatalf("Ошибка Open: %v", err)
+	}
+	defer db.Close()
+
+	if db.zdec == nil {
+		t.Fatal("Словарь не загружен")
+	}
+
+	dst := make([]byte, 0, 512)
+	tree.ForEach(func(n art.Node) bool {
+		val, ok, err := db.Find(n.Key(), dst)
+		if !ok || err != nil || string(val) != string(n.Value().([]byte)) {
+			t.Fatalf("Ошибка Find %s: ok %v, err %v", n.Key(), ok, err)
+		}
+		return true
+	})
+}
+
+func TestTrainDictionaryErrors(t *testing.T) {
+	tmpDir, _ := os.MkdirTemp("", "qwick_dict_err")
+	defer os.RemoveAll(tmpDir)
+
+	tree := New()
+	tree.Insert([]byte("k"), []byte("v"))
+
+	err := BuildWithOptions(tree, filepath.Join(tmpDir, "s2.qwick"), BuildOptions{
+		Compression:     compS2,
+		TrainDictionary: DictOptions{DictSize: 1024},
+	})
+	if err == nil {
+		t.Error("Ожидалась ошибка для словаря с S2")
+	}
+
+	err = BuildWithOptions(tree, filepath.Join(tmpDir, "tiny.qwick"), BuildOptions{
+		TrainDictionary: DictOptions{DictSize: 1024},
+	})
+	if err == nil {
+		t.Error("Ожидалась ошибка обучения словаря на вырожденной выборке")
+	}
+}
+
+func TestSampleValues(t *testing.T) {
+	tree := New()
+	for i := range 1000 {
+		tree.Insert([]byte(fmt.Sprintf("k%04d", i)), []byte(fmt.Sprint(i)))
+	}
+	// При total/n = 3.33 целый шаг 3 дошёл бы только до записи 897.
+	samples := sampleValues(tree, 300)
+	if len(samples) != 300 || string(samples[0]) != "0" || string(samples[299]) != "996" {
+		t.Errorf("выборка: %d значений, от %s до %s", len(samples), samples[0], samples[len(samples)-1])
+	}
+	if n := len(sampleValues(tree, 5000)); n != 1000 {
+		t.Errorf("выборка больше дерева: %d значений", n)
+	}
+}
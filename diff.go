@@ -0,0 +1,239 @@
+package qwick
+
+import (
+	"bytes"
+	"slices"
+	"time"
+
+	art "github.com/plar/go-adaptive-radix-tree/v2"
+)
+
+// ChangeKind - вид изменения ключа между двумя версиями базы.
+type ChangeKind int
+
+const (
+	Added    ChangeKind = iota + 1 // ключ есть только в новой версии
+	Removed                        // ключ есть только в старой версии
+	Modified                       // значение или срок действия ключа изменились
+)
+
+func (k ChangeKind) String() string {
+	switch k {
+	case Added:
+		return "added"
+	case Removed:
+		return "removed"
+	case Modified:
+		return "modified"
+	}
+	return "unknown"
+}
+
+// Change описывает изменение одного ключа. Old и New - распакованные значения
+// (Old == nil для Added, New == nil для Removed). У повторяющегося ключа (Values)
+// сравниваются наборы значений целиком: OldValues и NewValues содержат все значения
+// в порядке записей, а Old и New - первые из них. OldExpiry и NewExpiry - сроки
+// действия ключа (нулевое время - бессрочно). Срезы действительны только во время
+// вызова колбэка.
+type Change struct {
+	Kind      ChangeKind
+	Key       []byte
+	Old       []byte
+	New       []byte
+	OldValues [][]byte
+	NewValues [][]byte
+	OldExpiry time.Time
+	NewExpiry time.Time
+}
+
+// Delta возвращает изменение размера значений ключа в байтах.
+func (c Change) Delta() int64 {
+	var d int64
+	for _, v := range c.NewValues {
+		d += int64(len(v))
+	}
+	for _, v := range c.OldValues {
+		d -= int64(len(v))
+	}
+	return d
+}
+
+// Diff сравнивает базы a (старая) и b (новая) одним проходом по обоим индексам
+// и вызывает cb для каждого изменённого ключа в порядке ключей. Значения сравниваются
+// сначала в сыром виде, а при расхождении - после распаковки; изменение срока действия
+// тоже считается изменением. Надгробия дельта-файлов и истёкшие записи считаются
+// отсутствующими ключами. Если cb возвращает false, обход прекращается.
+// Файлы с пространствами имён сравниваются по отдельным Namespace.
+func Diff(a, b *MMAPDB, cb func(c Change) bool) error {
+	if err := rejectSpaces([]*MMAPDB{a, b}); err != nil {
+		return err
+	}
+	rawComparable := a.sameStorage(b)
+
+	it := newMergeIter([]*MMAPDB{a, b}, nil)
+	for it.next() {
+		var oldC, newC *mergeCursor
+		for _, c := range it.group {
+			if c.src == 0 {
+				oldC = c
+			} else {
+				newC = c
+			}
+		}
+		oldAt, newAt := oldC.expiry(), newC.expiry()
+		if rawComparable && oldC != nil && newC != nil && oldAt == newAt &&
+			slices.EqualFunc(oldC.rawValues(), newC.rawValues(), bytes.Equal) {
+			continue
+		}
+
+		ch := Change{Key: it.group[0].key}
+		var err error
+		if ch.OldValues, err = oldC.values(); err != nil {
+			return err
+		}
+		if ch.NewValues, err = newC.values(); err != nil {
+			return err
+		}
+		switch {
+		case ch.OldValues == nil && ch.NewValues == nil:
+			continue
+		case ch.OldValues == nil:
+			ch.Kind = Added
+		case ch.NewValues == nil:
+			ch.Kind = Removed
+		case oldAt == newAt && slices.EqualFunc(ch.OldValues, ch.NewValues, bytes.Equal):
+			continue
+		default:
+			ch.Kind = Modified
+		}
+		if ch.OldValues != nil {
+			ch.Old, ch.OldExpiry = ch.OldValues[0], expiryTime(oldAt)
+		}
+		if ch.NewValues != nil {
+			ch.New, ch.NewExpiry = ch.NewValues[0], expiryTime(newAt)
+		}
+		if !cb(ch) {
+			return nil
+		}
+	}
+	return nil
+}
+
+// rawValues возвращает хранимые значения видимых записей курсора.
+func (c *mergeCursor) rawValues() [][]byte {
+	var out [][]byte
+	for i := c.pos; i < c.end; i++ {
+		if !c.db.hidden(i) {
+			out = append(out, c.db.getValSlice(i))
+		}
+	}
+	return out
+}
+
+// expiry возвращает самый ранний срок действия видимых записей курсора
+// (UnixNano, 0 - бессрочно).
+func (c *mergeCursor) expiry() uint64 {
+	if c == nil {
+		return 0
+	}
+	var at uint64
+	for i := c.pos; i < c.end; i++ {
+		if e := c.db.expiry(i); e != 0 && !c.db.hidden(i) && (at == 0 || e < at) {
+			at = e
+		}
+	}
+	return at
+}
+
+// expiryTime переводит срок из UnixNano во время; 0 - нулевое время.
+func expiryTime(at uint64) time.Time {
+	if at == 0 {
+		return time.Time{}
+	}
+	return time.Unix(0, int64(at))
+}
+
+// values возвращает распакованные значения видимых записей курсора или nil, если
+// их нет. Значения повторяющегося ключа копируются: распаковка следующего может
+// переиспользовать буфер.
+func (c *mergeCursor) values() ([][]byte, error) {
+	if c == nil {
+		return nil, nil
+	}
+	var out [][]byte
+	for i := c.pos; i < c.end; i++ {
+		if c.db.hidden(i) {
+			continue
+		}
+		v, err := c.db.value(i)
+		if err != nil {
+			return nil, err
+		}
+		if c.end-c.pos > 1 {
+			v = append([]byte{}, v...)
+		}
+		out = append(out, v)
+	}
+	return out, nil
+}
+
+// sameStorage сообщает, что равенство сырых значений в db и other означает
+// равенство распакованных: одинаковы раскладка, кодек и словарь.
+func (db *MMAPDB) sameStorage(other *MMAPDB) bool {
+	if db.hdr.Flags&flagWide != 0 || other.hdr.Flags&flagWide != 0 {
+		// Несжатые огромные значения и сжатые могут совпасть байтами случайно.
+		return false
+	}
+	if (db.blocks != nil) != (other.blocks != nil) {
+		return false
+	}
+	if db.blocks != nil {
+		// В блочной раскладке getValSlice уже возвращает распакованные данные.
+		return true
+	}
+	if db.compression != other.compression || db.hdr.Flags&flagUncompressed != other.hdr.Flags&flagUncompressed {
+		return false
+	}
+	return bytes.Equal(db.section(secZstdDict), other.section(secZstdDict))
+}
+
+// DiffTree собирает изменения от a к b в дерево, пригодное для сборки дельта-файла:
+// NewOverlay(a, delta) даёт то же содержимое, что и b. Сроки действия переносятся
+// через WithExpiry, а ключ, истёкший в b, становится надгробием.
+func DiffTree(a, b *MMAPDB) (art.Tree, error) {
+	tree := New()
+	err := Diff(a, b, func(c Change) bool {
+		key := append([]byte(nil), c.Key...)
+		if c.Kind == Removed {
+			tree.Insert(key, Tombstone)
+			return true
+		}
+		var val any = append([]byte{}, c.New...)
+		if len(c.NewValues) > 1 {
+			vals := make(Values, len(c.NewValues))
+			for i, v := range c.NewValues {
+				vals[i] = append([]byte{}, v...)
+			}
+			val = vals
+		}
+		if !c.NewExpiry.IsZero() {
+			val = WithExpiry(val, c.NewExpiry)
+		}
+		tree.Insert(key, val)
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+	return tree, nil
+}
+
+// BuildDelta записывает в path дельта-файл с изменениями от a к b.
+// Параметры сжатия, словарь и метаданные берутся у b.
+func BuildDelta(a, b *MMAPDB, path string) error {
+	tree, err := DiffTree(a, b)
+	if err != nil {
+		return err
+	}
+	return BuildWithOptions(tree, path, b.buildOptions())
+}
@@ -0,0 +1,157 @@
+package qwick
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	art "github.com/plar/go-adaptive-radix-tree/v2"
+)
+
+func TestDiff(t *testing.T) {
+	tmpDir, _ := os.MkdirTemp("", "qwick_diff")
+	defer os.RemoveAll(tmpDir)
+
+	oldKV := map[string]string{"a": "1", "b": "2", "c": "3", "d": "same"}
+	newKV := map[string]string{"b": "2", "c": "33", "d": "same", "e": "5"}
+
+	for _, tc := range []struct {
+		name       string
+		oldOpts    BuildOptions
+		newOpts    BuildOptions
+		comparable bool
+	}{
+		{"zstd", BuildOptions{Compression: compZstd}, BuildOptions{Compression: compZstd}, true},
+		{"zstd-s2", BuildOptions{Compression: compZstd}, BuildOptions{Compression: compS2}, false},
+		{"blocks", BuildOptions{Layout: LayoutBlocks}, BuildOptions{Compression: compS2, Layout: LayoutBlocks}, true},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			a := buildTestDB(t, filepath.Join(tmpDir, tc.name+"-a.qwick"), oldKV, tc.oldOpts)
+			b := buildTestDB(t, filepath.Join(tmpDir, tc.name+"-b.qwick"), newKV, tc.newOpts)
+			if a.sameStorage(b) != tc.comparable {
+				t.Errorf("sameStorage = %v", !tc.comparable)
+			}
+
+			var got []string
+			var delta int64
+			err := Diff(a, b, func(c Change) bool {
+				got = append(got, fmt.Sprintf("%s %s %q>%q", c.Kind, c.Key, c.Old, c.New))
+				delta += c.Delta()
+				return true
+			})
+			if err != nil {
+				t.Fatalf("Ошибка Diff: %v", err)
+			}
+			want := `[removed a "1">"" modified c "3">"33" added e "">"5"]`
+			if fmt.Sprint(got) != want {
+				t.Errorf("Diff: получено %v, ожидалось %v", got, want)
+			}
+			if delta != 1 {
+				t.Errorf("Итоговая разница в байтах %d, ожидалось 1", delta)
+			}
+
+			// Дельта поверх старой версии даёт новую.
+			deltaPath := filepath.Join(tmpDir, tc.name+"-delta.qwick")
+			if err := BuildDelta(a, b, deltaPath); err != nil {
+				t.Fatalf("Ошибка BuildDelta: %v", err)
+			}
+			d, err := Open(deltaPath)
+			if err != nil {
+				t.Fatalf("Ошибка Open: %v", err)
+			}
+			defer d.Close()
+			got2 := make(map[string]string)
+			NewOverlay(a, d).Prefix(nil, nil, func(k, v []byte) bool {
+				got2[string(k)] = string(v)
+				return true
+			})
+			if fmt.Sprint(got2) != fmt.Sprint(newKV) {
+				t.Errorf("Overlay(старая, дельта): получено %v, ожидалось %v", got2, newKV)
+			}
+		})
+	}
+}
+
+func TestDiffStop(t *testing.T) {
+	tmpDir, _ := os.MkdirTemp("", "qwick_diff_stop")
+	defer os.RemoveAll(tmpDir)
+
+	a := buildTestDB(t, filepath.Join(tmpDir, "a.qwick"), map[string]string{"x": "1"}, BuildOptions{})
+	b := buildTestDB(t, filepath.Join(tmpDir, "b.qwick"), map[string]string{"a": "1", "b": "2"}, BuildOptions{})
+
+	n := 0
+	if err := Diff(a, b, func(c Change) bool { n++; return false }); err != nil || n != 1 {
+		t.Errorf("Ожидался один вызов, получено %d, err %v", n, err)
+	}
+}
+
+func TestDiffExpiry(t *testing.T) {
+	dir := t.TempDir()
+	start := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	now := start
+	clock := OpenOptions{Now: func() time.Time { return now }}
+	open := func(name string, tree art.Tree) *MMAPDB {
+		path := filepath.Join(dir, name)
+		if tree != nil {
+			if err := Build(tree, path); err != nil {
+				t.Fatalf("Ошибка Build: %v", err)
+			}
+		}
+		db, err := OpenWithOptions(path, clock)
+		if err != nil {
+			t.Fatalf("Ошибка Open: %v", err)
+		}
+		t.Cleanup(func() { db.Close() })
+		return db
+	}
+
+	ta := New()
+	ta.Insert([]byte("gone"), []byte("3"))
+	ta.Insert([]byte("keep"), []byte("1"))
+	ta.Insert([]byte("ttl"), WithExpiry([]byte("2"), start.Add(time.Hour)))
+	tb := New()
+	tb.Insert([]byte("gone"), WithExpiry([]byte("3"), start))
+	tb.Insert([]byte("keep"), WithExpiry([]byte("1"), start.Add(2*time.Hour)))
+	tb.Insert([]byte("ttl"), WithExpiry([]byte("2"), start.Add(time.Hour)))
+	a, b := open("a.qwick", ta), open("b.qwick", tb)
+
+	ttl := func(at time.Time) string {
+		if at.IsZero() {
+			return "-"
+		}
+		return at.Sub(start).String()
+	}
+	var got []string
+	if err := Diff(a, b, func(c Change) bool {
+		got = append(got, fmt.Sprintf("%s %s %s>%s", c.Kind, c.Key, ttl(c.OldExpiry), ttl(c.NewExpiry)))
+		return true
+	}); err != nil {
+		t.Fatalf("Ошибка Diff: %v", err)
+	}
+	want := "[removed gone ->- modified keep ->2h0m0s]"
+	if fmt.Sprint(got) != want {
+		t.Errorf("Diff: получено %v, ожидалось %v", got, want)
+	}
+
+	if err := BuildDelta(a, b, filepath.Join(dir, "delta.qwick")); err != nil {
+		t.Fatalf("Ошибка BuildDelta: %v", err)
+	}
+	o := NewOverlay(a, open("delta.qwick", nil))
+	for _, at := range []time.Duration{0, 90 * time.Minute, 3 * time.Hour} {
+		now = start.Add(at)
+		var gotO, wantB []string
+		o.Prefix(nil, nil, func(k, v []byte) bool {
+			gotO = append(gotO, string(k)+"="+string(v))
+			return true
+		})
+		b.Prefix(nil, nil, func(k, v []byte) bool {
+			wantB = append(wantB, string(k)+"="+string(v))
+			return true
+		})
+		if fmt.Sprint(gotO) != fmt.Sprint(wantB) {
+			t.Errorf("через %v: Overlay(a, дельта) = %v, b = %v", at, gotO, wantB)
+		}
+	}
+}
@@ -0,0 +1,87 @@
+package qwick
+
+import (
+	"encoding/binary"
+	"errors"
+	"time"
+)
+
+// Expiring - значение с ограниченным сроком действия. После At запись считается
+// отсутствующей при чтении, а Compact и Merge её отбрасывают (Merge с дельта-файлами
+// среди источников оставляет вместо неё надгробие).
+type Expiring struct {
+	Value any // значение записи: []byte, string, Values или любое другое
+	At    time.Time
+}
+
+// WithExpiry оборачивает значение для вставки в дерево со сроком действия до at.
+func WithExpiry(val any, at time.Time) Expiring {
+	return Expiring{Value: val, At: at}
+}
+
+// addExpiring дописывает значение и задаёт срок действия всем его записям.
+func (b *builder) addExpiring(key []byte, v Expiring) error {
+	if _, ok := v.Value.(tombstone); ok {
+		return errors.New("надгробие не может иметь срок действия")
+	}
+	first := len(b.indices)
+	if err := b.addValue(key, v.Value); err != nil {
+		return err
+	}
+	at := uint64(v.At.UnixNano())
+	if v.At.IsZero() || at == 0 {
+		return nil
+	}
+	for i := first; i < len(b.indices); i++ {
+		b.setExpiry(i, at)
+	}
+	return nil
+}
+
+// setExpiry задаёт срок действия записи i (UnixNano, 0 - бессрочно).
+func (b *builder) setExpiry(i int, at uint64) {
+	if at == 0 {
+		return
+	}
+	if len(b.expires) <= i {
+		b.expires = append(b.expires, make([]uint64, i+1-len(b.expires))...)
+	}
+	b.expires[i] = at
+}
+
+// encodeExpiry кодирует таблицу сроков: по uint64 на каждую запись индекса.
+func (b *builder) encodeExpiry() []byte {
+	out := make([]byte, 8*len(b.indices))
+	for i, at := range b.expires {
+		binary.LittleEndian.PutUint64(out[8*i:], at)
+	}
+	return out
+}
+
+// expiry возвращает срок действия записи i (UnixNano, 0 - бессрочно).
+func (db *MMAPDB) expiry(i uint64) uint64 {
+	if db.expires == nil {
+		return 0
+	}
+	return binary.LittleEndian.Uint64(db.expires[8*i:])
+}
+
+// expired сообщает, что срок действия записи i истёк.
+func (db *MMAPDB) expired(i uint64) bool {
+	at := db.expiry(i)
+	return at != 0 && int64(at) <= db.now().UnixNano()
+}
+
+// ExpiresAt возвращает срок действия ключа. ok=false, если ключа нет, он истёк
+// или бессрочен.
+func (db *MMAPDB) ExpiresAt(key []byte) (time.Time, bool) {
+	idx, ok := db.lookup(key)
+	if !ok {
+		return time.Time{}, false
+	}
+	at := db.expiry(idx)
+	if at == 0 {
+		return time.Time{}, false
+	}
+	return time.Unix(0, int64(at)), true
+}
@@ -0,0 +1,192 @@
+package qwick
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestExpiry(t *testing.T) {
+	dir := t.TempDir()
+	start := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	now := start
+	clock := func() time.Time { return now }
+
+	tree := New()
+	tree.Insert([]byte("forever"), []byte("v0"))
+	tree.Insert([]byte("promo1"), WithExpiry([]byte("v1"), start.Add(time.Hour)))
+	tree.Insert([]byte("promo2"), WithExpiry("v2", start.Add(2*time.Hour)))
+	tree.Insert([]byte("promo3"), WithExpiry(Values{[]byte("x"), []byte("y")}, start.Add(time.Hour)))
+
+	for _, layout := range []int{LayoutValues, LayoutBlocks} {
+		path := filepath.Join(dir, "exp.qwick")
+		if err := BuildWithOptions(tree, path, BuildOptions{Layout: layout}); err != nil {
+			t.Fatalf("Ошибка BuildWithOptions: %v", err)
+		}
+		db, err := OpenWithOptions(path, OpenOptions{Now: clock})
+		if err != nil {
+			t.Fatalf("Ошибка Open: %v", err)
+		}
+
+		now = start
+		want := []string{"forever=v0", "promo1=v1", "promo2=v2", "promo3=x", "promo3=y"}
+		if got := listDB(t, db); !reflect.DeepEqual(got, want) {
+			t.Errorf("до истечения: %v", got)
+		}
+		if at, ok := db.ExpiresAt([]byte("promo2")); !ok || !at.Equal(start.Add(2*time.Hour)) {
+			t.Errorf("ExpiresAt = %v, %v", at, ok)
+		}
+		if _, ok := db.ExpiresAt([]byte("forever")); ok {
+			t.Error("бессрочный ключ не должен иметь срока")
+		}
+
+		now = start.Add(time.Hour)
+		if _, ok, _ := db.Find([]byte("promo1"), nil); ok {
+			t.Error("Find вернул истёкший ключ")
+		}
+		if _, ok := db.GetRaw([]byte("promo1")); ok {
+			t.Error("GetRaw вернул истёкший ключ")
+		}
+		if n := db.Count([]byte("promo3")); n != 0 {
+			t.Errorf("Count истёкшего ключа = %d", n)
+		}
+		want = []string{"forever=v0", "promo2=v2"}
+		if got := listDB(t, db); !reflect.DeepEqual(got, want) {
+			t.Errorf("после истечения: %v", got)
+		}
+		db.Close()
+	}
+
+	// Файл без сроков не создаёт таблицу сроков.
+	plain := buildTestDB(t, filepath.Join(dir, "plain.qwick"), map[string]string{"a": "1"}, BuildOptions{})
+	if plain.section(secExpiry) != nil {
+		t.Error("лишняя секция сроков")
+	}
+}
+
+// listDB возвращает видимые записи как "ключ=значение" в порядке обхода.
+func listDB(t *testing.T, db *MMAPDB) []string {
+	t.Helper()
+	var out []string
+	if err := db.Prefix(nil, nil, func(k, v []byte) bool {
+		out = append(out, string(k)+"="+string(v))
+		return true
+	}); err != nil {
+		t.Fatalf("Ошибка Prefix: %v", err)
+	}
+	return out
+}
+
+func TestExpiryCompact(t *testing.T) {
+	dir := t.TempDir()
+	start := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	clock := func() time.Time { return start.Add(90 * time.Minute) }
+
+	tree := New()
+	tree.Insert([]byte("a"), []byte("1"))
+	tree.Insert([]byte("b"), WithExpiry([]byte("2"), start.Add(time.Hour)))
+	tree.Insert([]byte("c"), WithExpiry([]byte("3"), start.Add(2*time.Hour)))
+	path := filepath.Join(dir, "base.qwick")
+	if err := BuildWithOptions(tree, path, BuildOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	base, err := OpenWithOptions(path, OpenOptions{Now: clock})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ov := NewOverlay(base)
+	defer ov.Close()
+
+	out := filepath.Join(dir, "compact.qwick")
+	if err := Compact(out, ov); err != nil {
+		t.Fatalf("Ошибка Compact: %v", err)
+	}
+	db, err := OpenWithOptions(out, OpenOptions{Now: clock})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	if db.num != 2 {
+		t.Errorf("после Compact %d записей, ожидалось 2", db.num)
+	}
+	// Срок действия оставшейся записи переносится.
+	if at, ok := db.ExpiresAt([]byte("c")); !ok || !at.Equal(start.Add(2*time.Hour)) {
+		t.Errorf("ExpiresAt после Compact = %v, %v", at, ok)
+	}
+}
+
+func TestTypedExpiry(t *testing.T) {
+	start := time.Now()
+	b := NewTypedBuilder[string, string](StringKey{}, StringCodec{})
+	b.Put("a", "1")
+	b.PutExpiring("b", "2", start.Add(-time.Minute))
+	path := filepath.Join(t.TempDir(), "typed.qwick")
+	if err := b.Build(path, BuildOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	db, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	tdb := NewTyped[string, string](db, StringKey{}, StringCodec{})
+	if _, ok, _ := tdb.Get("b"); ok {
+		t.Error("истёкший ключ виден через Typed")
+	}
+	if v, ok, _ := tdb.Get("a"); !ok || v != "1" {
+		t.Errorf("Get(a) = %q, %v", v, ok)
+	}
+}
+
+func TestExpiryMerge(t *testing.T) {
+	dir := t.TempDir()
+	start := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	clock := func() time.Time { return start.Add(90 * time.Minute) }
+
+	tree := New()
+	tree.Insert([]byte("a"), []byte("1"))
+	tree.Insert([]byte("b"), WithExpiry([]byte("2"), start.Add(time.Hour)))
+	tree.Insert([]byte("c"), WithExpiry([]byte("3"), start.Add(2*time.Hour)))
+	path := filepath.Join(dir, "base.qwick")
+	if err := Build(tree, path); err != nil {
+		t.Fatal(err)
+	}
+	base, err := OpenWithOptions(path, OpenOptions{Now: clock})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer base.Close()
+	other := buildTestDB(t, filepath.Join(dir, "other.qwick"), map[string]string{"d": "4"}, BuildOptions{})
+	delta := buildDelta(t, filepath.Join(dir, "delta.qwick"), map[string]string{"e": "5"}, []string{"a"}, BuildOptions{})
+	defer delta.Close()
+
+	tests := []struct {
+		name  string
+		srcs  []*MMAPDB
+		num   uint64
+		tombs bool
+	}{
+		// Полные снимки: истёкшая запись просто исчезает.
+		{"snapshot", []*MMAPDB{base, other}, 3, false},
+		// С дельтой результат - тоже дельта: истёкший b скрывается надгробием.
+		{"delta", []*MMAPDB{base, delta}, 4, true},
+	}
+	for _, tt := range tests {
+		out := filepath.Join(dir, tt.name+".qwick")
+		if err := Merge(out, tt.srcs, LastWins); err != nil {
+			t.Fatalf("%s: ошибка Merge: %v", tt.name, err)
+		}
+		db, err := OpenWithOptions(out, OpenOptions{Now: clock})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if db.num != tt.num || (db.hdr.Flags&flagDelta != 0) != tt.tombs {
+			t.Errorf("%s: %d записей, флаги %b", tt.name, db.num, db.hdr.Flags)
+		}
+		if _, ok, _ := db.Find([]byte("b"), nil); ok {
+			t.Errorf("%s: истёкший ключ виден после Merge", tt.name)
+		}
+		db.Close()
+	}
+}
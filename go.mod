@@ -7,6 +7,5 @@ require (
 	github.com/klauspost/compress v1.18.2
 	github.com/plar/go-adaptive-radix-tree/v2 v2.0.4
 	golang.org/x/crypto v0.47.0
+	golang.org/x/sys v0.40.0
 )
-
-require golang.org/x/sys v0.40.0 // indirect
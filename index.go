@@ -0,0 +1,199 @@
+package qwick
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+)
+
+// IndexFunc извлекает из пары ключи вторичного индекса. Одна запись может попасть
+// в индекс под несколькими ключами или не попасть вовсе (nil).
+type IndexFunc func(key, value []byte) [][]byte
+
+// Секция вторичного индекса: NameLen(4) + Name + Count(8), затем Count записей
+// indexRefSize байт - KeyOff(4) + KeyLen(4) + Ordinal(8) - в порядке ключей индекса,
+// затем ключи индекса подряд. KeyOff отсчитывается от начала области ключей.
+const indexRefSize = uint64(4 + 4 + 8)
+
+// indexPair - ключ вторичного индекса и номер записи основного индекса.
+type indexPair struct {
+	key []byte
+	ord uint64
+}
+
+// extract дополняет вторичные индексы ключами записи ord.
+func (b *builder) extract(key, val []byte, ord uint64) {
+	if b.secondary == nil {
+		b.secondary = make(map[string][]indexPair, len(b.opts.Indexes))
+	}
+	for name, fn := range b.opts.Indexes {
+		for _, ik := range fn(key, val) {
+			b.secondary[name] = append(b.secondary[name], indexPair{append([]byte(nil), ik...), ord})
+		}
+	}
+}
+
+// addIndexSections записывает вторичные индексы в порядке имён.
+func (b *builder) addIndexSections() error {
+	names := make([]string, 0, len(b.opts.Indexes))
+	for name := range b.opts.Indexes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		pairs := b.secondary[name]
+		sort.SliceStable(pairs, func(i, j int) bool {
+			if c := bytes.Compare(pairs[i].key, pairs[j].key); c != 0 {
+				return c < 0
+			}
+			return pairs[i].ord < pairs[j].ord
+		})
+
+		buf := binary.LittleEndian.AppendUint32(nil, uint32(len(name)))
+		buf = append(buf, name...)
+		buf = binary.LittleEndian.AppendUint64(buf, uint64(len(pairs)))
+		var keyOff uint64
+		for _, p := range pairs {
+			if keyOff > math.MaxUint32 {
+				return fmt.Errorf("индекс %s: ключи превышают 4GB", name)
+			}
+			buf = binary.LittleEndian.AppendUint32(buf, uint32(keyOff))
+			buf = binary.LittleEndian.AppendUint32(buf, uint32(len(p.key)))
+			buf = binary.LittleEndian.AppendUint64(buf, p.ord)
+			keyOff += uint64(len(p.key))
+		}
+		for _, p := range pairs {
+			buf = append(buf, p.key...)
+		}
+		if err := b.addSection(secIndex, buf); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Index - вторичный индекс базы. Записи индекса ссылаются на записи основного
+// индекса, поэтому значения не дублируются.
+type Index struct {
+	db   *MMAPDB
+	name string
+	refs []byte
+	keys []byte
+	num  uint64
+}
+
+var errIndexNotFound = errors.New("вторичный индекс не найден")
+
+// parseIndex разбирает секцию вторичного индекса.
+func (db *MMAPDB) parseIndex(data []byte) (*Index, error) {
+	bad := errors.New("некорректная секция вторичного индекса")
+	if len(data) < 4 {
+		return nil, bad
+	}
+	nameLen := uint64(binary.LittleEndian.Uint32(data))
+	if nameLen+12 > uint64(len(data)) {
+		return nil, bad
+	}
+	idx := &Index{db: db, name: string(data[4 : 4+nameLen])}
+	rest := data[4+nameLen:]
+	idx.num = binary.LittleEndian.Uint64(rest)
+	rest = rest[8:]
+	if idx.num > uint64(len(rest))/indexRefSize {
+		return nil, bad
+	}
+	idx.refs = rest[:idx.num*indexRefSize]
+	idx.keys = rest[idx.num*indexRefSize:]
+	return idx, nil
+}
+
+// Index возвращает вторичный индекс с именем name. Для неизвестного имени
+// возвращается nil, методы которого возвращают ошибку.
+func (db *MMAPDB) Index(name string) *Index {
+	return db.indexes[name]
+}
+
+// Indexes возвращает имена вторичных индексов базы.
+func (db *MMAPDB) Indexes() []string {
+	names := make([]string, 0, len(db.indexes))
+	for name := range db.indexes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// ref возвращает ключ индекса и номер основной записи для позиции i.
+func (idx *Index) ref(i uint64) ([]byte, uint64, bool) {
+	r := idx.refs[i*indexRefSize : (i+1)*indexRefSize]
+	off := uint64(binary.LittleEndian.Uint32(r[0:4]))
+	n := uint64(binary.LittleEndian.Uint32(r[4:8]))
+	if off+n > uint64(len(idx.keys)) {
+		return nil, 0, false
+	}
+	return idx.keys[off : off+n], binary.LittleEndian.Uint64(r[8:16]), true
+}
+
+// lowerBound возвращает первую позицию с ключом индекса >= key.
+func (idx *Index) lowerBound(key []byte) uint64 {
+	lo, hi := uint64(0), idx.num
+	for lo < hi {
+		mid := lo + (hi-lo)/2
+		k, _, ok := idx.ref(mid)
+		if ok && bytes.Compare(k, key) < 0 {
+			lo = mid + 1
+		} else {
+			hi = mid
+		}
+	}
+	return lo
+}
+
+// Get вызывает cb с ключом и распакованным значением каждой основной записи,
+// попавшей в индекс под ключом key.
+func (idx *Index) Get(key []byte, cb func(key, val []byte) bool) error {
+	return idx.scan(key, func(k []byte) bool { return bytes.Equal(k, key) }, cb)
+}
+
+// Prefix вызывает cb для основных записей, ключ индекса которых начинается с prefix,
+// в порядке ключей индекса.
+func (idx *Index) Prefix(prefix []byte, cb func(key, val []byte) bool) error {
+	return idx.scan(prefix, hasPrefix(prefix), cb)
+}
+
+func (idx *Index) scan(lo []byte, inRange func(k []byte) bool, cb func(key, val []byte) bool) error {
+	if idx == nil {
+		return errIndexNotFound
+	}
+	db := idx.db
+	for i := idx.lowerBound(lo); i < idx.num; i++ {
+		k, ord, ok := idx.ref(i)
+		if !ok {
+			return fmt.Errorf("индекс %s: запись %d выходит за границы секции", idx.name, i)
+		}
+		if !inRange(k) {
+			return nil
+		}
+		if ord >= db.num {
+			return fmt.Errorf("индекс %s: ссылка на несуществующую запись %d", idx.name, ord)
+		}
+		if db.hidden(ord) {
+			continue
+		}
+		pk := db.getKeySlice(ord)
+		val, ok, err := db.valueAt(ord, nil)
+		if err != nil {
+			return err
+		}
+		if pk == nil || !ok {
+			return errCorruptValue
+		}
+		if !cb(pk, val) {
+			return nil
+		}
+	}
+	return nil
+}
@@ -0,0 +1,150 @@
+package qwick
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func userIndexes() map[string]IndexFunc {
+	return map[string]IndexFunc{
+		"email": func(key, value []byte) [][]byte {
+			var u struct{ Email string }
+			if json.Unmarshal(value, &u) != nil || u.Email == "" {
+				return nil
+			}
+			return [][]byte{[]byte(u.Email)}
+		},
+		"tag": func(key, value []byte) [][]byte {
+			var u struct{ Tags []string }
+			json.Unmarshal(value, &u)
+			out := make([][]byte, len(u.Tags))
+			for i, t := range u.Tags {
+				out[i] = []byte(t)
+			}
+			return out
+		},
+	}
+}
+
+func TestSecondaryIndex(t *testing.T) {
+	tmpDir, _ := os.MkdirTemp("", "qwick_index")
+	defer os.RemoveAll(tmpDir)
+
+	for _, layout := range []int{LayoutValues, LayoutBlocks} {
+		path := filepath.Join(tmpDir, fmt.Sprintf("idx%d.qwick", layout))
+		if err := BuildWithOptions(jsonTree(300), path, BuildOptions{Layout: layout, Indexes: userIndexes()}); err != nil {
+			t.Fatalf("Ошибка BuildWithOptions: %v", err)
+		}
+		db, err := Open(path)
+		if err != nil {
+			t.Fatalf("Ошибка Open: %v", err)
+		}
+
+		if fmt.Sprint(db.Indexes()) != "[email tag]" {
+			t.Errorf("Indexes: %v", db.Indexes())
+		}
+
+		var keys []string
+		err = db.Index("email").Get([]byte("user42@example.com"), func(k, v []byte) bool {
+			keys = append(keys, string(k))
+			var u struct{ ID int }
+			if json.Unmarshal(v, &u) != nil || u.ID != 42 {
+				t.Errorf("Неверное значение для %s: %s", k, v)
+			}
+			return true
+		})
+		if err != nil || fmt.Sprint(keys) != "[user:000042]" {
+			t.Errorf("Get: %v, err %v", keys, err)
+		}
+
+		// Неуникальный индекс: tag5 есть у записей с i%17 == 5.
+		n := 0
+		db.Index("tag").Get([]byte("tag5"), func(k, v []byte) bool { n++; return true })
+		if n != 18 {
+			t.Errorf("Get tag5: %d записей, ожидалось 18", n)
+		}
+
+		keys = keys[:0]
+		db.Index("email").Prefix([]byte("user29"), func(k, v []byte) bool {
+			keys = append(keys, string(k))
+			return len(keys) < 3
+		})
+		if fmt.Sprint(keys) != "[user:000290 user:000291 user:000292]" {
+			t.Errorf("Prefix: %v", keys)
+		}
+
+		if err := db.Index("phone").Get([]byte("x"), func(k, v []byte) bool { return true }); err == nil {
+			t.Error("Ожидалась ошибка для неизвестного индекса")
+		}
+		db.Close()
+	}
+}
+
+func TestSecondaryIndexDelta(t *testing.T) {
+	tmpDir, _ := os.MkdirTemp("", "qwick_index_delta")
+	defer os.RemoveAll(tmpDir)
+
+	tree := New()
+	tree.Insert([]byte("u1"), []byte(`{"Email":"a@x"}`))
+	tree.Insert([]byte("u2"), []byte(`{"Email":"b@x"}`))
+	tree.Insert([]byte("u3"), Tombstone)
+	path := filepath.Join(tmpDir, "delta.qwick")
+	opts := BuildOptions{Compression: compS2, Indexes: userIndexes()}
+	if err := BuildWithOptions(tree, path, opts); err != nil {
+		t.Fatalf("Ошибка BuildWithOptions: %v", err)
+	}
+	db, err := Open(path)
+	if err != nil {
+		t.Fatalf("Ошибка Open: %v", err)
+	}
+	defer db.Close()
+
+	var keys []string
+	db.Index("email").Prefix(nil, func(k, v []byte) bool {
+		keys = append(keys, string(k))
+		return true
+	})
+	if fmt.Sprint(keys) != "[u1 u2]" {
+		t.Errorf("Prefix: %v", keys)
+	}
+
+	// Слияние с индексами пересобирает их по распакованным значениям.
+	other := buildTestDB(t, filepath.Join(tmpDir, "other.qwick"), map[string]string{"u2": `{"Email":"c@x"}`}, BuildOptions{Compression: compS2})
+	merged := filepath.Join(tmpDir, "merged.qwick")
+	if err := MergeWithOptions(merged, []*MMAPDB{db, other}, LastWins, opts); err != nil {
+		t.Fatalf("Ошибка MergeWithOptions: %v", err)
+	}
+	mdb, err := Open(merged)
+	if err != nil {
+		t.Fatalf("Ошибка Open: %v", err)
+	}
+	defer mdb.Close()
+	keys = keys[:0]
+	mdb.Index("email").Get([]byte("c@x"), func(k, v []byte) bool {
+		keys = append(keys, string(k))
+		return true
+	})
+	if fmt.Sprint(keys) != "[u2]" {
+		t.Errorf("Get после слияния: %v", keys)
+	}
+
+	// Без функций извлечения индексы пропали бы молча: Merge и Compact отказываются.
+	lost := filepath.Join(tmpDir, "lost.qwick")
+	if err := Merge(lost, []*MMAPDB{other, db}, LastWins); err == nil {
+		t.Error("Merge: ожидалась ошибка для источника с индексами")
+	}
+	if err := Compact(lost, NewOverlay(db, other)); err == nil {
+		t.Error("Compact: ожидалась ошибка для слоя с индексами")
+	}
+	partial := opts
+	partial.Indexes = map[string]IndexFunc{"email": opts.Indexes["email"]}
+	if err := CompactWithOptions(lost, NewOverlay(db, other), partial); err == nil {
+		t.Error("CompactWithOptions: ожидалась ошибка для пропущенного индекса tag")
+	}
+	if err := CompactWithOptions(lost, NewOverlay(db, other), opts); err != nil {
+		t.Errorf("Ошибка CompactWithOptions: %v", err)
+	}
+}
@@ -0,0 +1,164 @@
+// Package keyenc кодирует ключи qwick с сохранением порядка: байтовое сравнение
+// закодированных ключей (bytes.Compare, как в индексе qwick) совпадает с естественным
+// порядком значений. Это делает Range и Prefix осмысленными для чисел, времени и
+// составных ключей.
+//
+// Числа и время занимают фиксированное число байт. Строки и срезы байт экранируются
+// (0x00 -> 0x00 0xFF) и завершаются 0x00 0x01, поэтому их можно ставить в начало
+// составного ключа. Decode-функции возвращают значение и число прочитанных байт.
+package keyenc
+
+import (
+	"encoding/binary"
+	"errors"
+	"math"
+	"time"
+)
+
+var (
+	// ErrShort - закодированный ключ короче ожидаемого.
+	ErrShort = errors.New("keyenc: ключ короче ожидаемого")
+	// ErrEscape - некорректная экранированная последовательность.
+	ErrEscape = errors.New("keyenc: некорректное экранирование")
+)
+
+// AppendUint64 дописывает v как 8 байт big-endian.
+func AppendUint64(dst []byte, v uint64) []byte {
+	return binary.BigEndian.AppendUint64(dst, v)
+}
+
+// DecodeUint64 читает значение, записанное AppendUint64.
+func DecodeUint64(b []byte) (uint64, int, error) {
+	if len(b) < 8 {
+		return 0, 0, ErrShort
+	}
+	return binary.BigEndian.Uint64(b), 8, nil
+}
+
+// AppendInt64 дописывает v как 8 байт big-endian с инвертированным знаковым битом,
+// чтобы отрицательные числа шли раньше положительных.
+func AppendInt64(dst []byte, v int64) []byte {
+	return binary.BigEndian.AppendUint64(dst, uint64(v)^(1<<63))
+}
+
+// DecodeInt64 читает значение, записанное AppendInt64.
+func DecodeInt64(b []byte) (int64, int, error) {
+	if len(b) < 8 {
+		return 0, 0, ErrShort
+	}
+	return int64(binary.BigEndian.Uint64(b) ^ (1 << 63)), 8, nil
+}
+
+// AppendFloat64 дописывает v так, что -Inf < отрицательные < -0 < +0 < положительные < +Inf.
+// NaN с нулевым знаком попадает после +Inf.
+func AppendFloat64(dst []byte, v float64) []byte {
+	u := math.Float64bits(v)
+	if u&(1<<63) != 0 {
+		u = ^u
+	} else {
+		u |= 1 << 63
+	}
+	return binary.BigEndian.AppendUint64(dst, u)
+}
+
+// DecodeFloat64 читает значение, записанное AppendFloat64.
+func DecodeFloat64(b []byte) (float64, int, error) {
+	if len(b) < 8 {
+		return 0, 0, ErrShort
+	}
+	u := binary.BigEndian.Uint64(b)
+	if u&(1<<63) != 0 {
+		u &^= 1 << 63
+	} else {
+		u = ^u
+	}
+	return math.Float64frombits(u), 8, nil
+}
+
+// AppendTime дописывает момент времени как секунды Unix (AppendInt64) и наносекунды
+// (4 байта big-endian), всего 12 байт. Часовой пояс не сохраняется.
+func AppendTime(dst []byte, t time.Time) []byte {
+	dst = AppendInt64(dst, t.Unix())
+	return binary.BigEndian.AppendUint32(dst, uint32(t.Nanosecond()))
+}
+
+// DecodeTime читает значение, записанное AppendTime, и возвращает его в UTC.
+func DecodeTime(b []byte) (time.Time, int, error) {
+	if len(b) < 12 {
+		return time.Time{}, 0, ErrShort
+	}
+	sec, _, _ := DecodeInt64(b)
+	nsec := binary.BigEndian.Uint32(b[8:12])
+	if nsec >= 1e9 {
+		return time.Time{}, 0, errors.New("keyenc: некорректные наносекунды")
+	}
+	return time.Unix(sec, int64(nsec)).UTC(), 12, nil
+}
+
+// AppendString дописывает экранированную строку с терминатором.
+func AppendString(dst []byte, s string) []byte {
+	for i := 0; i < len(s); i++ {
+		if s[i] == 0 {
+			dst = append(dst, 0, 0xFF)
+		} else {
+			dst = append(dst, s[i])
+		}
+	}
+	return append(dst, 0, 1)
+}
+
+// DecodeString читает строку, записанную AppendString.
+func DecodeString(b []byte) (string, int, error) {
+	v, n, err := DecodeBytes(b)
+	return string(v), n, err
+}
+
+// AppendBytes дописывает экранированный срез байт с терминатором.
+func AppendBytes(dst []byte, v []byte) []byte {
+	for _, c := range v {
+		if c == 0 {
+			dst = append(dst, 0, 0xFF)
+		} else {
+			dst = append(dst, c)
+		}
+	}
+	return append(dst, 0, 1)
+}
+
+// DecodeBytes читает срез, записанный AppendBytes. Результат - новый срез.
+func DecodeBytes(b []byte) ([]byte, int, error) {
+	out := []byte{}
+	for i := 0; i < len(b); i++ {
+		if b[i] != 0 {
+			out = append(out, b[i])
+			continue
+		}
+		if i+1 >= len(b) {
+			break
+		}
+		switch b[i+1] {
+		case 1:
+			return out, i + 2, nil
+		case 0xFF:
+			out = append(out, 0)
+			i++
+		default:
+			return nil, 0, ErrEscape
+		}
+	}
+	return nil, 0, ErrShort
+}
+
+// PrefixEnd возвращает наименьший ключ, больший всех ключей с префиксом prefix,
+// - верхнюю границу для Range. Для пустого префикса или префикса из одних 0xFF
+// границы нет, и возвращается nil (Range без верхней границы).
+func PrefixEnd(prefix []byte) []byte {
+	end := append([]byte(nil), prefix...)
+	for i := len(end) - 1; i >= 0; i-- {
+		if end[i] < 0xFF {
+			end[i]++
+			return end[:i+1]
+		}
+	}
+	return nil
+}
@@ -0,0 +1,100 @@
+package keyenc
+
+import (
+	"bytes"
+	"math"
+	"testing"
+	"time"
+)
+
+// checkOrder проверяет, что закодированные значения идут строго по возрастанию
+// и декодируются обратно.
+func checkOrder[T any](t *testing.T, vals []T, enc func([]byte, T) []byte, dec func([]byte) (T, int, error), eq func(a, b T) bool) {
+	t.Helper()
+	var prev []byte
+	for i, v := range vals {
+		b := enc([]byte("x"), v)[1:]
+		if i > 0 && bytes.Compare(prev, b) >= 0 {
+			t.Errorf("Нарушен порядок: %v не больше предыдущего", v)
+		}
+		prev = b
+
+		got, n, err := dec(append(b, 0xAA))
+		if err != nil || n != len(b) || !eq(got, v) {
+			t.Errorf("Декодирование %v: получено %v, n %d, err %v", v, got, n, err)
+		}
+	}
+}
+
+func TestNumbers(t *testing.T) {
+	checkOrder(t, []uint64{0, 1, 9, 10, 100, math.MaxUint64}, AppendUint64, DecodeUint64,
+		func(a, b uint64) bool { return a == b })
+	checkOrder(t, []int64{math.MinInt64, -100, -10, -1, 0, 1, 10, math.MaxInt64}, AppendInt64, DecodeInt64,
+		func(a, b int64) bool { return a == b })
+	checkOrder(t, []float64{math.Inf(-1), -math.MaxFloat64, -1, -math.SmallestNonzeroFloat64, 0,
+		math.SmallestNonzeroFloat64, 0.5, 1, math.MaxFloat64, math.Inf(1)}, AppendFloat64, DecodeFloat64,
+		func(a, b float64) bool { return a == b })
+
+	if _, _, err := DecodeInt64([]byte{1, 2}); err != ErrShort {
+		t.Errorf("Ожидалась ErrShort, получено %v", err)
+	}
+	if f, _, _ := DecodeFloat64(AppendFloat64(nil, math.NaN())); !math.IsNaN(f) {
+		t.Error("NaN не сохранился")
+	}
+}
+
+func TestTime(t *testing.T) {
+	base := time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC)
+	checkOrder(t, []time.Time{
+		time.Date(1, 1, 1, 0, 0, 0, 0, time.UTC),
+		base.AddDate(-70, 0, 0),
+		base.Add(-time.Nanosecond),
+		base,
+		base.Add(time.Nanosecond),
+		base.AddDate(300, 0, 0),
+	}, AppendTime, DecodeTime, time.Time.Equal)
+
+	// Часовой пояс не влияет на порядок.
+	msk := time.FixedZone("MSK", 3*3600)
+	if !bytes.Equal(AppendTime(nil, base.In(msk)), AppendTime(nil, base)) {
+		t.Error("Кодировка зависит от часового пояса")
+	}
+	bad := AppendTime(nil, base)
+	bad[8] = 0xFF
+	if _, _, err := DecodeTime(bad); err == nil {
+		t.Error("Ожидалась ошибка для некорректных наносекунд")
+	}
+}
+
+func TestStrings(t *testing.T) {
+	checkOrder(t, []string{"", "\x00", "\x00\x00", "\x00\x01", "\x01", "a", "a\x00", "a\x00b", "ab", "b", "\xff"},
+		AppendString, DecodeString, func(a, b string) bool { return a == b })
+	checkOrder(t, [][]byte{{}, {0}, {0, 0xFF}, {1}}, AppendBytes, DecodeBytes, bytes.Equal)
+
+	for _, b := range [][]byte{[]byte("abc"), {'a', 0}} {
+		if _, _, err := DecodeString(b); err != ErrShort {
+			t.Errorf("DecodeString(%q): ожидалась ErrShort, получено %v", b, err)
+		}
+	}
+	if _, _, err := DecodeString([]byte{'a', 0, 7}); err != ErrEscape {
+		t.Errorf("Ожидалась ErrEscape, получено %v", err)
+	}
+}
+
+func TestPrefixEnd(t *testing.T) {
+	for _, tc := range []struct{ in, want []byte }{
+		{[]byte("abc"), []byte("abd")},
+		{[]byte{'a', 0xFF, 0xFF}, []byte("b")},
+		{[]byte{0xFF}, nil},
+		{nil, nil},
+	} {
+		if got := PrefixEnd(tc.in); !bytes.Equal(got, tc.want) {
+			t.Errorf("PrefixEnd(%q) = %q, ожидалось %q", tc.in, got, tc.want)
+		}
+	}
+	in := []byte("ab")
+	PrefixEnd(in)
+	if string(in) != "ab" {
+		t.Error("PrefixEnd изменил аргумент")
+	}
+}
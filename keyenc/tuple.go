@@ -0,0 +1,106 @@
+package keyenc
+
+import (
+	"fmt"
+	"time"
+)
+
+// Метки типов элементов кортежа. Порядок меток задаёт порядок элементов разных типов.
+const (
+	tagBytes  = 0x01
+	tagString = 0x02
+	tagInt    = 0x10
+	tagUint   = 0x11
+	tagFloat  = 0x20
+	tagTime   = 0x30
+)
+
+// AppendTuple дописывает кортеж элементов. Каждый элемент предваряется меткой типа,
+// поэтому кортеж декодируется без схемы, а кодировка любого начала кортежа является
+// префиксом кодировки всего кортежа. Поддерживаются []byte, string, все целые типы
+// (знаковые как int64, беззнаковые как uint64), float32/float64 и time.Time.
+// Элементы одного типа сравниваются по значению, разных типов - по метке.
+func AppendTuple(dst []byte, elems ...any) ([]byte, error) {
+	for _, e := range elems {
+		switch v := e.(type) {
+		case []byte:
+			dst = AppendBytes(append(dst, tagBytes), v)
+		case string:
+			dst = AppendString(append(dst, tagString), v)
+		case int:
+			dst = AppendInt64(append(dst, tagInt), int64(v))
+		case int8:
+			dst = AppendInt64(append(dst, tagInt), int64(v))
+		case int16:
+			dst = AppendInt64(append(dst, tagInt), int64(v))
+		case int32:
+			dst = AppendInt64(append(dst, tagInt), int64(v))
+		case int64:
+			dst = AppendInt64(append(dst, tagInt), v)
+		case uint:
+			dst = AppendUint64(append(dst, tagUint), uint64(v))
+		case uint8:
+			dst = AppendUint64(append(dst, tagUint), uint64(v))
+		case uint16:
+			dst = AppendUint64(append(dst, tagUint), uint64(v))
+		case uint32:
+			dst = AppendUint64(append(dst, tagUint), uint64(v))
+		case uint64:
+			dst = AppendUint64(append(dst, tagUint), v)
+		case float32:
+			dst = AppendFloat64(append(dst, tagFloat), float64(v))
+		case float64:
+			dst = AppendFloat64(append(dst, tagFloat), v)
+		case time.Time:
+			dst = AppendTime(append(dst, tagTime), v)
+		default:
+			return nil, fmt.Errorf("keyenc: неподдерживаемый тип элемента кортежа %T", e)
+		}
+	}
+	return dst, nil
+}
+
+// Tuple - то же, что AppendTuple(nil, elems...), но паникует на неподдерживаемом типе.
+// Удобно для ключей с заранее известными типами.
+func Tuple(elems ...any) []byte {
+	b, err := AppendTuple(nil, elems...)
+	if err != nil {
+		panic(err)
+	}
+	return b
+}
+
+// DecodeTuple разбирает кортеж, записанный AppendTuple. Целые возвращаются как
+// int64/uint64, числа с плавающей точкой - как float64, время - в UTC.
+func DecodeTuple(b []byte) ([]any, error) {
+	var elems []any
+	for len(b) > 0 {
+		var (
+			v   any
+			n   int
+			err error
+		)
+		switch tag, rest := b[0], b[1:]; tag {
+		case tagBytes:
+			v, n, err = DecodeBytes(rest)
+		case tagString:
+			v, n, err = DecodeString(rest)
+		case tagInt:
+			v, n, err = DecodeInt64(rest)
+		case tagUint:
+			v, n, err = DecodeUint64(rest)
+		case tagFloat:
+			v, n, err = DecodeFloat64(rest)
+		case tagTime:
+			v, n, err = DecodeTime(rest)
+		default:
+			return nil, fmt.Errorf("keyenc: неизвестная метка типа 0x%02x", tag)
+		}
+		if err != nil {
+			return nil, err
+		}
+		elems = append(elems, v)
+		b = b[1+n:]
+	}
+	return elems, nil
+}
@@ -0,0 +1,64 @@
+package keyenc
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestTuple(t *testing.T) {
+	ts := time.Date(2026, 10, 18, 0, 0, 0, 0, time.UTC)
+	tuples := [][]any{
+		{[]byte{0}},
+		{"ru"},
+		{"ru", int64(-5)},
+		{"ru", 0},
+		{"ru", int8(7)},
+		{"ru", 7, "x"},
+		{"ru", uint16(1)},
+		{"ru\x00"},
+		{"rub", 1.5},
+		{int64(-1)},
+		{uint(3), ts},
+		{float32(-2)},
+		{ts},
+	}
+	var prev []byte
+	for _, tup := range tuples {
+		b, err := AppendTuple(nil, tup...)
+		if err != nil {
+			t.Fatalf("Ошибка AppendTuple(%v): %v", tup, err)
+		}
+		if prev != nil && bytes.Compare(prev, b) >= 0 {
+			t.Errorf("Нарушен порядок на %v", tup)
+		}
+		prev = b
+		if _, err := DecodeTuple(b); err != nil {
+			t.Errorf("Ошибка DecodeTuple(%v): %v", tup, err)
+		}
+	}
+
+	got, err := DecodeTuple(Tuple("ru", 7, uint8(2), -0.5, ts, []byte("b")))
+	if err != nil {
+		t.Fatalf("Ошибка DecodeTuple: %v", err)
+	}
+	if want := fmt.Sprint([]any{"ru", int64(7), uint64(2), -0.5, ts, []byte("b")}); fmt.Sprint(got) != want {
+		t.Errorf("DecodeTuple: получено %v, ожидалось %v", got, want)
+	}
+
+	// Начало кортежа - префикс всего кортежа.
+	if !bytes.HasPrefix(Tuple("ru", 7), Tuple("ru")) {
+		t.Error("Кортеж не начинается со своего префикса")
+	}
+
+	if _, err := AppendTuple(nil, struct{}{}); err == nil {
+		t.Error("Ожидалась ошибка для неподдерживаемого типа")
+	}
+	if _, err := DecodeTuple([]byte{0x7F}); err == nil {
+		t.Error("Ожидалась ошибка для неизвестной метки")
+	}
+	if _, err := DecodeTuple([]byte{tagInt, 1}); err == nil {
+		t.Error("Ожидалась ошибка для обрезанного кортежа")
+	}
+}
@@ -0,0 +1,310 @@
+package qwick
+
+import (
+	"bytes"
+	"container/heap"
+	"errors"
+	"fmt"
+	"slices"
+)
+
+// ResolveFunc выбирает итоговое значение ключа, найденного в нескольких источниках.
+// vals - распакованные значения в порядке источников (все значения повторяющегося
+// ключа подряд). Результат nil исключает ключ.
+type ResolveFunc func(key []byte, vals [][]byte) ([]byte, error)
+
+// MergePolicy определяет, какое значение попадает в результат при совпадении ключей.
+type MergePolicy struct {
+	firstWins bool
+	resolve   ResolveFunc
+}
+
+var (
+	// LastWins оставляет значение из последнего источника, содержащего ключ.
+	LastWins = MergePolicy{}
+	// FirstWins оставляет значение из первого источника, содержащего ключ.
+	FirstWins = MergePolicy{firstWins: true}
+)
+
+// Resolver возвращает политику, в которой конфликт разрешает fn.
+func Resolver(fn ResolveFunc) MergePolicy {
+	return MergePolicy{resolve: fn}
+}
+
+// Merge объединяет отсортированные базы srcs в новый файл dst за один проход (k-way merge).
+// Параметры сжатия, словарь и метаданные берутся у первого источника. Значения
+// источников с тем же кодеком копируются как есть, без распаковки и повторного сжатия.
+// Истёкшие записи отбрасываются; если среди источников есть дельта-файлы, результат
+// тоже дельта: удалённые и истёкшие ключи сохраняются надгробиями. Функции вторичных
+// индексов в файле не хранятся: для источников с индексами нужен MergeWithOptions.
+func Merge(dst string, srcs []*MMAPDB, policy MergePolicy) error {
+	if len(srcs) == 0 {
+		return errors.New("нет источников для слияния")
+	}
+	return MergeWithOptions(dst, srcs, policy, srcs[0].buildOptions())
+}
+
+// MergeWithOptions объединяет базы srcs в файл dst с заданными параметрами сборки.
+// Каждый вторичный индекс источников должен быть объявлен в opts.Indexes. Файлы
+// с пространствами имён не принимаются: сливайте их пространства по отдельности.
+func MergeWithOptions(dst string, srcs []*MMAPDB, policy MergePolicy, opts BuildOptions) error {
+	if len(srcs) == 0 {
+		return errors.New("нет источников для слияния")
+	}
+	if err := rejectSpaces(srcs); err != nil {
+		return err
+	}
+	if err := checkIndexes(srcs, opts); err != nil {
+		return err
+	}
+	// Без дельта-источников результат - полный снимок: надгробиям нечего скрывать.
+	keepTombs := slices.ContainsFunc(srcs, func(db *MMAPDB) bool { return db.tombs != nil })
+	return buildFile(dst, opts, nil, func(b *builder) error {
+		return mergeInto(b, srcs, policy, !keepTombs)
+	})
+}
+
+// errSpacesSource возвращается для файлов с пространствами имён: их записи лежат
+// только в пространствах, а корневой индекс пуст.
+var errSpacesSource = errors.New("файлы с пространствами имён не поддерживаются: работайте с каждым Namespace отдельно")
+
+// rejectSpaces отвергает источники с пространствами имён, иначе результат молча
+// оказался бы пустым.
+func rejectSpaces(srcs []*MMAPDB) error {
+	if slices.ContainsFunc(srcs, func(db *MMAPDB) bool { return db.spaces != nil }) {
+		return errSpacesSource
+	}
+	return nil
+}
+
+// checkIndexes не даёт молча потерять вторичные индексы источников: функции
+// извлечения в файле не хранятся, и пересобрать индекс можно только по opts.Indexes.
+func checkIndexes(srcs []*MMAPDB, opts BuildOptions) error {
+	for _, db := range srcs {
+		for _, name := range db.Indexes() {
+			if _, ok := opts.Indexes[name]; !ok {
+				return fmt.Errorf("источник содержит вторичный индекс %q: укажите его в BuildOptions.Indexes", name)
+			}
+		}
+	}
+	return nil
+}
+
+// buildOptions восстанавливает параметры сборки, совместимые с кодеком базы.
+func (db *MMAPDB) buildOptions() BuildOptions {
+	opts := BuildOptions{Compression: db.compression, ZstdLevel: 1}
+	if db.compression == 0 && db.hdr.Flags&flagUncompressed == 0 {
+		// Авто-режим: порог неизвестен, но скопированные значения сохраняют свой кодек.
+		opts.SizeCutover = 256
+	}
+	if db.blocks != nil {
+		opts.Layout = LayoutBlocks
+	}
+	if d := db.section(secZstdDict); d != nil {
+		opts.dict = append([]byte(nil), d...)
+	}
+	opts.Metadata = db.Metadata()
+	return opts
+}
+
+// sameCodec сообщает, что хранимые значения db можно дописать в b без перекодирования.
+func (b *builder) sameCodec(db *MMAPDB) bool {
+	// Вторичным индексам нужны распакованные значения.
+	if b.opts.Layout != LayoutValues || db.blocks != nil || len(b.opts.Indexes) > 0 || db.hdr.Flags&flagWide != 0 {
+		return false
+	}
+	if b.compression != db.compression || b.uncompressed() != (db.hdr.Flags&flagUncompressed != 0) {
+		return false
+	}
+	return bytes.Equal(b.dict, db.section(secZstdDict))
+}
+
+// mergeInto пишет в b объединение srcs. Повторяющийся ключ источника (Values) - это
+// набор значений: политика выбирает набор целиком, а ResolveFunc получает значения
+// всех наборов подряд. Удалённые и истёкшие ключи сохраняются надгробиями, а при
+// dropDeleted (сжатие слоёв, слияние снимков) - отбрасываются. Сроки действия
+// оставшихся записей переносятся в результат.
+func mergeInto(b *builder, srcs []*MMAPDB, policy MergePolicy, dropDeleted bool) error {
+	verbatim := make([]bool, len(srcs))
+	for i, db := range srcs {
+		verbatim[i] = b.sameCodec(db)
+	}
+
+	var vals [][]byte
+	it := newMergeIter(srcs, nil)
+	for it.next() {
+		group := it.group
+		key := group[0].key
+
+		if len(group) > 1 && policy.resolve != nil {
+			vals = vals[:0]
+			var at uint64 // самый ранний срок действия среди объединяемых значений
+			for _, c := range group {
+				for i := c.pos; i < c.end; i++ {
+					if c.db.hidden(i) {
+						continue
+					}
+					if e := c.db.expiry(i); e != 0 && (at == 0 || int64(e) < int64(at)) {
+						at = e
+					}
+					v, err := c.db.value(i)
+					if err != nil {
+						return err
+					}
+					vals = append(vals, append([]byte{}, v...))
+				}
+			}
+			if len(vals) == 0 {
+				if err := b.dropOrTombstone(key, dropDeleted); err != nil {
+					return err
+				}
+				continue
+			}
+			res, err := policy.resolve(key, vals)
+			if err != nil {
+				return err
+			}
+			if res == nil {
+				continue
+			}
+			if err := b.add(key, res); err != nil {
+				return err
+			}
+			b.setExpiry(len(b.indices)-1, at)
+			continue
+		}
+
+		win := group[len(group)-1]
+		if policy.firstWins {
+			win = group[0]
+		}
+		n := 0
+		for i := win.pos; i < win.end; i++ {
+			if win.db.hidden(i) {
+				continue
+			}
+			if err := b.copyEntry(win.db, i, key, verbatim[win.src]); err != nil {
+				return err
+			}
+			n++
+		}
+		if n == 0 {
+			if err := b.dropOrTombstone(key, dropDeleted); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// copyEntry переносит запись i базы db со сроком действия. При verbatim хранимое
+// значение копируется без распаковки.
+func (b *builder) copyEntry(db *MMAPDB, i uint64, key []byte, verbatim bool) error {
+	if verbatim {
+		raw := db.getValSlice(i)
+		if raw == nil {
+			return errCorruptValue
+		}
+		if err := b.addEncoded(key, raw); err != nil {
+			return err
+		}
+	} else {
+		v, err := db.value(i)
+		if err != nil {
+			return err
+		}
+		if err := b.add(key, v); err != nil {
+			return err
+		}
+	}
+	b.setExpiry(len(b.indices)-1, db.expiry(i))
+	return nil
+}
+
+var errCorruptValue = errors.New("значение выходит за границы файла")
+
+// mergeCursor - позиция обхода одного источника: записи [pos, end) с ключом key.
+type mergeCursor struct {
+	db  *MMAPDB
+	src int
+	pos uint64
+	end uint64
+	key []byte
+}
+
+// mergeHeap упорядочивает курсоры по ключу, а при равных ключах - по номеру источника.
+type mergeHeap []*mergeCursor
+
+func (h mergeHeap) Len() int { return len(h) }
+func (h mergeHeap) Less(i, j int) bool {
+	if c := bytes.Compare(h[i].key, h[j].key); c != 0 {
+		return c < 0
+	}
+	return h[i].src < h[j].src
+}
+func (h mergeHeap) Swap(i, j int) { h[i], h[j] = h[j], h[i] }
+func (h *mergeHeap) Push(x any)   { *h = append(*h, x.(*mergeCursor)) }
+func (h *mergeHeap) Pop() any {
+	old := *h
+	c := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return c
+}
+
+// mergeIter обходит несколько баз в порядке ключей. На каждом шаге group содержит
+// курсоры всех источников с минимальным ключом, упорядоченные по номеру источника;
+// курсор охватывает все записи повторяющегося ключа.
+type mergeIter struct {
+	h     mergeHeap
+	group []*mergeCursor
+}
+
+// newMergeIter создаёт итератор, начинающий с первого ключа >= lo.
+func newMergeIter(srcs []*MMAPDB, lo []byte) *mergeIter {
+	it := &mergeIter{}
+	for i, db := range srcs {
+		pos, _ := db.findIndex(lo)
+		c := &mergeCursor{db: db, src: i, pos: pos}
+		if c.load() {
+			it.h = append(it.h, c)
+		}
+	}
+	heap.Init(&it.h)
+	return it
+}
+
+// load читает ключ в текущей позиции курсора и находит конец его записей.
+// false - источник исчерпан.
+func (c *mergeCursor) load() bool {
+	if c.pos >= c.db.num {
+		return false
+	}
+	c.key = c.db.getKeySlice(c.pos)
+	c.end = c.pos + 1
+	if c.db.hdr.Flags&flagDups != 0 {
+		for c.end < c.db.num && bytes.Equal(c.db.getKeySlice(c.end), c.key) {
+			c.end++
+		}
+	}
+	return c.key != nil
+}
+
+// next продвигает курсоры предыдущей группы и собирает следующую.
+func (it *mergeIter) next() bool {
+	for _, c := range it.group {
+		c.pos = c.end
+		if c.load() {
+			heap.Push(&it.h, c)
+		}
+	}
+	it.group = it.group[:0]
+	if len(it.h) == 0 {
+		return false
+	}
+	first := heap.Pop(&it.h).(*mergeCursor)
+	it.group = append(it.group, first)
+	for len(it.h) > 0 && bytes.Equal(it.h[0].key, first.key) {
+		it.group = append(it.group, heap.Pop(&it.h).(*mergeCursor))
+	}
+	return true
+}
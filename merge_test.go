@@ -0,0 +1,260 @@
+package qwick
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func buildTestDB(t *testing.T, path string, kv map[string]string, opts BuildOptions) *MMAPDB {
+	t.Helper()
+	tree := New()
+	for k, v := range kv {
+		tree.Insert([]byte(k), []byte(v))
+	}
+	if err := BuildWithOptions(tree, path, opts); err != nil {
+		t.Fatalf("Ошибка BuildWithOptions: %v", err)
+	}
+	db, err := Open(path)
+	if err != nil {
+		t.Fatalf("Ошибка Open: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+func dumpDB(t *testing.T, db *MMAPDB) map[string]string {
+	t.Helper()
+	out := make(map[string]string)
+	var prev []byte
+	err := db.Prefix(nil, nil, func(k, v []byte) bool {
+		if prev != nil && bytes.Compare(prev, k) >= 0 {
+			t.Errorf("Нарушен порядок ключей: %q >= %q", prev, k)
+		}
+		prev = append(prev[:0], k...)
+		out[string(k)] = string(v)
+		return true
+	})
+	if err != nil {
+		t.Fatalf("Ошибка Prefix: %v", err)
+	}
+	return out
+}
+
+func TestMerge(t *testing.T) {
+	tmpDir, _ := os.MkdirTemp("", "qwick_merge")
+	defer os.RemoveAll(tmpDir)
+
+	opts := BuildOptions{Compression: compZstd}
+	a := buildTestDB(t, filepath.Join(tmpDir, "a.qwick"), map[string]string{"a": "1", "b": "old", "c": "3"}, opts)
+	b := buildTestDB(t, filepath.Join(tmpDir, "b.qwick"), map[string]string{"b": "new", "d": "4"}, opts)
+
+	tests := []struct {
+		name   string
+		policy MergePolicy
+		want   map[string]string
+	}{
+		{"LastWins", LastWins, map[string]string{"a": "1", "b": "new", "c": "3", "d": "4"}},
+		{"FirstWins", FirstWins, map[string]string{"a": "1", "b": "old", "c": "3", "d": "4"}},
+		{"Resolver", Resolver(func(key []byte, vals [][]byte) ([]byte, error) {
+			return bytes.Join(vals, []byte("+")), nil
+		}), map[string]string{"a": "1", "b": "old+new", "c": "3", "d": "4"}},
+		{"ResolverDrop", Resolver(func(key []byte, vals [][]byte) ([]byte, error) {
+			return nil, nil
+		}), map[string]string{"a": "1", "c": "3", "d": "4"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dst := filepath.Join(tmpDir, tt.name+".qwick")
+			if err := Merge(dst, []*MMAPDB{a, b}, tt.policy); err != nil {
+				t.Fatalf("Ошибка Merge: %v", err)
+			}
+			db, err := Open(dst)
+			if err != nil {
+				t.Fatalf("Ошибка Open: %v", err)
+			}
+			defer db.Close()
+			if got := dumpDB(t, db); fmt.Sprint(got) != fmt.Sprint(tt.want) {
+				t.Errorf("Получено %v, ожидалось %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMergeCodecs(t *testing.T) {
+	tmpDir, _ := os.MkdirTemp("", "qwick_merge_codecs")
+	defer os.RemoveAll(tmpDir)
+
+	big := string(bytes.Repeat([]byte("payload "), 100))
+	zstdDB := buildTestDB(t, filepath.Join(tmpDir, "zstd.qwick"), map[string]string{"k1": big, "k2": "v2"}, BuildOptions{Compression: compZstd})
+	s2DB := buildTestDB(t, filepath.Join(tmpDir, "s2.qwick"), map[string]string{"k3": big}, BuildOptions{Compression: compS2})
+	blockDB := buildTestDB(t, filepath.Join(tmpDir, "blocks.qwick"), map[string]string{"k4": big}, BuildOptions{Layout: LayoutBlocks})
+
+	srcs := []*MMAPDB{zstdDB, s2DB, blockDB}
+	if !(&builder{opts: BuildOptions{}, compression: compZstd}).sameCodec(zstdDB) {
+		t.Error("Кодек zstd должен совпадать")
+	}
+
+	dst := filepath.Join(tmpDir, "merged.qwick")
+	if err := Merge(dst, srcs, LastWins); err != nil {
+		t.Fatalf("Ошибка Merge: %v", err)
+	}
+	db, err := Open(dst)
+	if err != nil {
+		t.Fatalf("Ошибка Open: %v", err)
+	}
+	defer db.Close()
+
+	want := map[string]string{"k1": big, "k2": "v2", "k3": big, "k4": big}
+	if got := dumpDB(t, db); fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("Неверный результат слияния: %d ключей", len(got))
+	}
+	// Значение zstd-источника скопировано побайтно.
+	rawSrc, _ := zstdDB.GetRaw([]byte("k1"))
+	rawDst, _ := db.GetRaw([]byte("k1"))
+	if !bytes.Equal(rawSrc, rawDst) {
+		t.Error("Значение с совпадающим кодеком должно копироваться как есть")
+	}
+
+	if err := Merge(dst, nil, LastWins); err == nil {
+		t.Error("Ожидалась ошибка для пустого списка источников")
+	}
+}
+
+func TestMergeValues(t *testing.T) {
+	dir := t.TempDir()
+	build := func(name string, kv map[string]any) *MMAPDB {
+		tree := New()
+		for k, v := range kv {
+			tree.Insert([]byte(k), v)
+		}
+		path := filepath.Join(dir, name)
+		if err := Build(tree, path); err != nil {
+			t.Fatalf("Ошибка Build: %v", err)
+		}
+		db, err := Open(path)
+		if err != nil {
+			t.Fatalf("Ошибка Open: %v", err)
+		}
+		t.Cleanup(func() { db.Close() })
+		return db
+	}
+	a := build("a.qwick", map[string]any{"k": Values{[]byte("1"), []byte("2")}, "z": []byte("z")})
+	b := build("b.qwick", map[string]any{"k": []byte("3")})
+
+	merge := func(name string, srcs []*MMAPDB, policy MergePolicy) *MMAPDB {
+		path := filepath.Join(dir, name)
+		if err := Merge(path, srcs, policy); err != nil {
+			t.Fatalf("Ошибка Merge: %v", err)
+		}
+		db, err := Open(path)
+		if err != nil {
+			t.Fatalf("Ошибка Open: %v", err)
+		}
+		t.Cleanup(func() { db.Close() })
+		if err := db.Verify(); err != nil {
+			t.Errorf("%s: ошибка Verify: %v", name, err)
+		}
+		return db
+	}
+	join := Resolver(func(key []byte, vals [][]byte) ([]byte, error) {
+		return bytes.Join(vals, []byte(",")), nil
+	})
+
+	tests := []struct {
+		name   string
+		srcs   []*MMAPDB
+		policy MergePolicy
+		want   string
+		count  uint64
+	}{
+		{"last", []*MMAPDB{a, b}, LastWins, "[k=3 z=z]", 1},
+		{"first", []*MMAPDB{a, b}, FirstWins, "[k=1 k=2 z=z]", 2},
+		{"last_rev", []*MMAPDB{b, a}, LastWins, "[k=1 k=2 z=z]", 2},
+		{"resolve", []*MMAPDB{a, b}, join, "[k=1,2,3 z=z]", 1},
+	}
+	merged := map[string]*MMAPDB{}
+	for _, tt := range tests {
+		db := merge(tt.name+".qwick", tt.srcs, tt.policy)
+		merged[tt.name] = db
+		if got := fmt.Sprint(listDB(t, db)); got != tt.want {
+			t.Errorf("%s: получено %s, ожидалось %s", tt.name, got, tt.want)
+		}
+		if n := db.Count([]byte("k")); n != tt.count {
+			t.Errorf("%s: Count(k) = %d, ожидалось %d", tt.name, n, tt.count)
+		}
+	}
+
+	// Diff сравнивает наборы значений целиком.
+	var changes []string
+	if err := Diff(a, merged["last"], func(c Change) bool {
+		changes = append(changes, fmt.Sprintf("%s %s %q→%q %d", c.Kind, c.Key, c.OldValues, c.NewValues, c.Delta()))
+		return true
+	}); err != nil {
+		t.Fatalf("Ошибка Diff: %v", err)
+	}
+	if want := `[modified k ["1" "2"]→["3"] -1]`; fmt.Sprint(changes) != want {
+		t.Errorf("Diff: %v, ожидалось %s", changes, want)
+	}
+	if err := Diff(a, merged["first"], func(c Change) bool {
+		t.Errorf("Diff одинаковых наборов: %s %s", c.Kind, c.Key)
+		return true
+	}); err != nil {
+		t.Fatalf("Ошибка Diff: %v", err)
+	}
+
+	// Слой оверлея задаёт весь набор значений ключа.
+	overlay := func(layers ...*MMAPDB) string {
+		var out []string
+		if err := NewOverlay(layers...).Prefix(nil, nil, func(k, v []byte) bool {
+			out = append(out, string(k)+"="+string(v))
+			return true
+		}); err != nil {
+			t.Fatalf("Ошибка Prefix: %v", err)
+		}
+		return fmt.Sprint(out)
+	}
+	if got := overlay(a, b); got != "[k=3 z=z]" {
+		t.Errorf("Overlay(a, b): %s", got)
+	}
+	if got := overlay(b, a); got != "[k=1 k=2 z=z]" {
+		t.Errorf("Overlay(b, a): %s", got)
+	}
+	if v, ok, err := NewOverlay(b, a).Find([]byte("k"), nil); !ok || err != nil || string(v) != "1" {
+		t.Errorf("Overlay.Find: %q, %v, %v", v, ok, err)
+	}
+
+	// Дельта переносит набор значений: оверлей восстанавливает новую версию.
+	for _, pair := range [][2]*MMAPDB{{a, merged["last"]}, {merged["last"], a}} {
+		path := filepath.Join(dir, "delta.qwick")
+		if err := BuildDelta(pair[0], pair[1], path); err != nil {
+			t.Fatalf("Ошибка BuildDelta: %v", err)
+		}
+		delta, err := Open(path)
+		if err != nil {
+			t.Fatalf("Ошибка Open: %v", err)
+		}
+		if got, want := overlay(pair[0], delta), fmt.Sprint(listDB(t, pair[1])); got != want {
+			t.Errorf("Overlay с дельтой: %s, ожидалось %s", got, want)
+		}
+		delta.Close()
+	}
+
+	compacted := filepath.Join(dir, "compacted.qwick")
+	if err := Compact(compacted, NewOverlay(b, a)); err != nil {
+		t.Fatalf("Ошибка Compact: %v", err)
+	}
+	cdb, err := Open(compacted)
+	if err != nil {
+		t.Fatalf("Ошибка Open: %v", err)
+	}
+	defer cdb.Close()
+	if err := cdb.Verify(); err != nil {
+		t.Errorf("Compact: ошибка Verify: %v", err)
+	}
+	if got := fmt.Sprint(listDB(t, cdb)); got != "[k=1 k=2 z=z]" {
+		t.Errorf("Compact: %s", got)
+	}
+}
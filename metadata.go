@@ -0,0 +1,76 @@
+package qwick
+
+import (
+	"encoding/binary"
+	"errors"
+	"sort"
+)
+
+// encodeMetadata сериализует метаданные: Count(4), затем для каждой пары в порядке
+// ключей KeyLen(4) + Key + ValLen(4) + Val. Порядок фиксирован, чтобы сборка
+// оставалась воспроизводимой.
+func encodeMetadata(meta map[string][]byte) []byte {
+	keys := make([]string, 0, len(meta))
+	for k := range meta {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	buf := binary.LittleEndian.AppendUint32(nil, uint32(len(keys)))
+	for _, k := range keys {
+		buf = binary.LittleEndian.AppendUint32(buf, uint32(len(k)))
+		buf = append(buf, k...)
+		buf = binary.LittleEndian.AppendUint32(buf, uint32(len(meta[k])))
+		buf = append(buf, meta[k]...)
+	}
+	return buf
+}
+
+var errCorruptMetadata = errors.New("некорректная секция метаданных")
+
+func decodeMetadata(b []byte) (map[string][]byte, error) {
+	field := func() ([]byte, bool) {
+		if len(b) < 4 {
+			return nil, false
+		}
+		n := uint64(binary.LittleEndian.Uint32(b))
+		if n > uint64(len(b)-4) {
+			return nil, false
+		}
+		f := b[4 : 4+n]
+		b = b[4+n:]
+		return f, true
+	}
+
+	if len(b) < 4 {
+		return nil, errCorruptMetadata
+	}
+	count := binary.LittleEndian.Uint32(b)
+	b = b[4:]
+	meta := make(map[string][]byte, min(count, 1024))
+	for range count {
+		k, ok := field()
+		if !ok {
+			return nil, errCorruptMetadata
+		}
+		v, ok := field()
+		if !ok {
+			return nil, errCorruptMetadata
+		}
+		meta[string(k)] = append([]byte{}, v...)
+	}
+	return meta, nil
+}
+
+// Metadata возвращает метаданные, записанные при сборке (BuildOptions.Metadata),
+// или nil, если их нет. Возвращается копия: её можно изменять.
+func (db *MMAPDB) Metadata() map[string][]byte {
+	if db.meta == nil {
+		return nil
+	}
+	meta := make(map[string][]byte, len(db.meta))
+	for k, v := range db.meta {
+		meta[k] = append([]byte{}, v...)
+	}
+	return meta
+}
@@ -0,0 +1,71 @@
+package qwick
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestMetadata(t *testing.T) {
+	tmpDir, _ := os.MkdirTemp("", "qwick_meta")
+	defer os.RemoveAll(tmpDir)
+
+	meta := map[string][]byte{
+		"snapshot": []byte("2026-10-18T00:00:00Z#42"),
+		"schema":   []byte("3"),
+		"owner":    []byte("search"),
+		"empty":    {},
+	}
+	db := buildTestDB(t, filepath.Join(tmpDir, "meta.qwick"), map[string]string{"a": "1"}, BuildOptions{Metadata: meta})
+
+	got := db.Metadata()
+	if fmt.Sprint(got) != fmt.Sprint(meta) {
+		t.Errorf("Metadata: получено %q, ожидалось %q", got, meta)
+	}
+	got["owner"][0] = 'X'
+	if !bytes.Equal(db.Metadata()["owner"], []byte("search")) {
+		t.Error("Metadata должна возвращать копию")
+	}
+
+	plain := buildTestDB(t, filepath.Join(tmpDir, "plain.qwick"), map[string]string{"a": "1"}, BuildOptions{})
+	if plain.Metadata() != nil {
+		t.Error("Файл без метаданных должен возвращать nil")
+	}
+
+	enc := encodeMetadata(meta)
+	for _, n := range []int{0, 3, 9, len(enc) - 1} {
+		if _, err := decodeMetadata(enc[:n]); err == nil {
+			t.Errorf("Ожидалась ошибка для обрезанных метаданных (%d байт)", n)
+		}
+	}
+}
+
+func TestMetadataMergeCompact(t *testing.T) {
+	tmpDir, _ := os.MkdirTemp("", "qwick_meta_merge")
+	defer os.RemoveAll(tmpDir)
+
+	meta := map[string][]byte{"snapshot": []byte("42"), "schema": []byte("3")}
+	a := buildTestDB(t, filepath.Join(tmpDir, "a.qwick"), map[string]string{"a": "1"}, BuildOptions{Metadata: meta})
+	b := buildTestDB(t, filepath.Join(tmpDir, "b.qwick"), map[string]string{"b": "2"}, BuildOptions{Metadata: map[string][]byte{"snapshot": []byte("43")}})
+
+	merged := filepath.Join(tmpDir, "merged.qwick")
+	if err := Merge(merged, []*MMAPDB{a, b}, LastWins); err != nil {
+		t.Fatalf("Ошибка Merge: %v", err)
+	}
+	compacted := filepath.Join(tmpDir, "compacted.qwick")
+	if err := Compact(compacted, NewOverlay(a, b)); err != nil {
+		t.Fatalf("Ошибка Compact: %v", err)
+	}
+	for _, path := range []string{merged, compacted} {
+		db, err := Open(path)
+		if err != nil {
+			t.Fatalf("Ошибка Open: %v", err)
+		}
+		if got := db.Metadata(); fmt.Sprint(got) != fmt.Sprint(meta) {
+			t.Errorf("%s: метаданные %q, ожидалось %q", filepath.Base(path), got, meta)
+		}
+		db.Close()
+	}
+}
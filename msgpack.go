@@ -0,0 +1,701 @@
+package qwick
+
+import (
+	"bytes"
+	"encoding"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// MsgpackCodec кодирует значения в формате MessagePack: компактно и с типами внутри
+// данных, поэтому старые записи читаются после добавления или удаления полей.
+// Структуры пишутся как map по именам экспортируемых полей (тег msgpack задаёт имя,
+// "-" исключает поле), ключи map сортируются. Типы с encoding.BinaryMarshaler
+// (например, time.Time) хранятся как bin. Поддерживаются bool, числа, строки,
+// []byte, срезы, массивы, map, структуры, указатели и any.
+type MsgpackCodec[T any] struct{}
+
+func (MsgpackCodec[T]) Encode(v T) ([]byte, error) {
+	return appendMsgpack(nil, reflect.ValueOf(&v).Elem())
+}
+
+func (MsgpackCodec[T]) Decode(data []byte) (T, error) {
+	var v T
+	d := msgpackDecoder{data: data}
+	if err := d.decode(reflect.ValueOf(&v).Elem()); err != nil {
+		return v, err
+	}
+	if d.pos != len(data) {
+		return v, fmt.Errorf("msgpack: лишние %d байт после значения", len(data)-d.pos)
+	}
+	return v, nil
+}
+
+var (
+	binaryMarshalerType   = reflect.TypeFor[encoding.BinaryMarshaler]()
+	binaryUnmarshalerType = reflect.TypeFor[encoding.BinaryUnmarshaler]()
+)
+
+// binaryType сообщает, что тип кодируется своими MarshalBinary/UnmarshalBinary.
+func binaryType(t reflect.Type) bool {
+	pt := reflect.PointerTo(t)
+	return pt.Implements(binaryUnmarshalerType) && (t.Implements(binaryMarshalerType) || pt.Implements(binaryMarshalerType))
+}
+
+// msgpackField - поле структуры, попадающее в кодирование.
+type msgpackField struct {
+	name  string
+	index []int
+}
+
+var msgpackFieldCache sync.Map // reflect.Type -> []msgpackField
+
+func msgpackFields(t reflect.Type) []msgpackField {
+	if f, ok := msgpackFieldCache.Load(t); ok {
+		return f.([]msgpackField)
+	}
+	var fields []msgpackField
+	for _, sf := range reflect.VisibleFields(t) {
+		if !sf.IsExported() || sf.Anonymous && indirectType(sf.Type).Kind() == reflect.Struct || !settable(t, sf.Index) {
+			continue
+		}
+		name := sf.Name
+		if tag, _, _ := strings.Cut(sf.Tag.Get("msgpack"), ","); tag == "-" {
+			continue
+		} else if tag != "" {
+			name = tag
+		}
+		fields = append(fields, msgpackField{name: name, index: sf.Index})
+	}
+	msgpackFieldCache.Store(t, fields)
+	return fields
+}
+
+func indirectType(t reflect.Type) reflect.Type {
+	if t.Kind() == reflect.Pointer {
+		return t.Elem()
+	}
+	return t
+}
+
+// settable сообщает, что путь к полю не проходит через неэкспортируемую встроенную структуру.
+func settable(t reflect.Type, index []int) bool {
+	for i := range index[:len(index)-1] {
+		sf := indirectType(t).FieldByIndex(index[:i+1])
+		if !sf.IsExported() {
+			return false
+		}
+	}
+	return true
+}
+
+func appendMsgpack(b []byte, v reflect.Value) ([]byte, error) {
+	if v.Kind() != reflect.Pointer && v.Kind() != reflect.Interface && binaryType(v.Type()) {
+		m, ok := v.Interface().(encoding.BinaryMarshaler)
+		if !ok {
+			if !v.CanAddr() {
+				p := reflect.New(v.Type())
+				p.Elem().Set(v)
+				v = p.Elem()
+			}
+			m = v.Addr().Interface().(encoding.BinaryMarshaler)
+		}
+		data, err := m.MarshalBinary()
+		if err != nil {
+			return nil, err
+		}
+		return appendMsgpackBytes(b, data)
+	}
+
+	switch v.Kind() {
+	case reflect.Invalid:
+		return append(b, 0xc0), nil
+	case reflect.Pointer, reflect.Interface:
+		if v.IsNil() {
+			return append(b, 0xc0), nil
+		}
+		return appendMsgpack(b, v.Elem())
+	case reflect.Bool:
+		if v.Bool() {
+			return append(b, 0xc3), nil
+		}
+		return append(b, 0xc2), nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return appendMsgpackInt(b, v.Int()), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return appendMsgpackUint(b, v.Uint()), nil
+	case reflect.Float32:
+		return binary.BigEndian.AppendUint32(append(b, 0xca), math.Float32bits(float32(v.Float()))), nil
+	case reflect.Float64:
+		return binary.BigEndian.AppendUint64(append(b, 0xcb), math.Float64bits(v.Float())), nil
+	case reflect.String:
+		b, err := appendMsgpackHeader(b, v.Len(), 0xa0, 32, 0xd9, 0xda, 0xdb)
+		return append(b, v.String()...), err
+	case reflect.Slice:
+		if v.IsNil() {
+			return append(b, 0xc0), nil
+		}
+		if v.Type().Elem().Kind() == reflect.Uint8 {
+			return appendMsgpackBytes(b, v.Bytes())
+		}
+		return appendMsgpackArray(b, v)
+	case reflect.Array:
+		if v.Type().Elem().Kind() == reflect.Uint8 {
+			data := make([]byte, v.Len())
+			reflect.Copy(reflect.ValueOf(data), v)
+			return appendMsgpackBytes(b, data)
+		}
+		return appendMsgpackArray(b, v)
+	case reflect.Map:
+		if v.IsNil() {
+			return append(b, 0xc0), nil
+		}
+		return appendMsgpackMap(b, v)
+	case reflect.Struct:
+		fields := msgpackFields(v.Type())
+		b, err := appendMsgpackHeader(b, len(fields), 0x80, 16, 0, 0xde, 0xdf)
+		if err != nil {
+			return nil, err
+		}
+		for _, f := range fields {
+			b, _ = appendMsgpackHeader(b, len(f.name), 0xa0, 32, 0xd9, 0xda, 0xdb)
+			b = append(b, f.name...)
+			fv, err := v.FieldByIndexErr(f.index)
+			if err != nil {
+				// Поле внутри nil-указателя на встроенную структуру.
+				fv = reflect.Value{}
+			}
+			if b, err = appendMsgpack(b, fv); err != nil {
+				return nil, err
+			}
+		}
+		return b, nil
+	}
+	return nil, fmt.Errorf("msgpack: тип %s не поддерживается", v.Type())
+}
+
+func appendMsgpackInt(b []byte, i int64) []byte {
+	switch {
+	case i >= 0:
+		return appendMsgpackUint(b, uint64(i))
+	case i >= -32:
+		return append(b, byte(i))
+	case i >= math.MinInt8:
+		return append(b, 0xd0, byte(i))
+	case i >= math.MinInt16:
+		return binary.BigEndian.AppendUint16(append(b, 0xd1), uint16(i))
+	case i >= math.MinInt32:
+		return binary.BigEndian.AppendUint32(append(b, 0xd2), uint32(i))
+	}
+	return binary.BigEndian.AppendUint64(append(b, 0xd3), uint64(i))
+}
+
+func appendMsgpackUint(b []byte, u uint64) []byte {
+	switch {
+	case u < 0x80:
+		return append(b, byte(u))
+	case u <= math.MaxUint8:
+		return append(b, 0xcc, byte(u))
+	case u <= math.MaxUint16:
+		return binary.BigEndian.AppendUint16(append(b, 0xcd), uint16(u))
+	case u <= math.MaxUint32:
+		return binary.BigEndian.AppendUint32(append(b, 0xce), uint32(u))
+	}
+	return binary.BigEndian.AppendUint64(append(b, 0xcf), u)
+}
+
+// appendMsgpackHeader пишет длину n: в fix-форме (fix|n при n < fixMax), иначе кодом
+// c8, c16 или c32 с 8-, 16- или 32-битной длиной. Нулевой код означает, что формы нет.
+func appendMsgpackHeader(b []byte, n int, fix byte, fixMax int, c8, c16, c32 byte) ([]byte, error) {
+	switch {
+	case fix != 0 && n < fixMax:
+		return append(b, fix|byte(n)), nil
+	case c8 != 0 && n <= math.MaxUint8:
+		return append(b, c8, byte(n)), nil
+	case n <= math.MaxUint16:
+		return binary.BigEndian.AppendUint16(append(b, c16), uint16(n)), nil
+	case uint64(n) <= math.MaxUint32:
+		return binary.BigEndian.AppendUint32(append(b, c32), uint32(n)), nil
+	}
+	return nil, errors.New("msgpack: слишком длинное значение")
+}
+
+func appendMsgpackBytes(b, data []byte) ([]byte, error) {
+	b, err := appendMsgpackHeader(b, len(data), 0, 0, 0xc4, 0xc5, 0xc6)
+	return append(b, data...), err
+}
+
+func appendMsgpackArray(b []byte, v reflect.Value) ([]byte, error) {
+	b, err := appendMsgpackHeader(b, v.Len(), 0x90, 16, 0, 0xdc, 0xdd)
+	for i := 0; i < v.Len() && err == nil; i++ {
+		b, err = appendMsgpack(b, v.Index(i))
+	}
+	return b, err
+}
+
+// appendMsgpackMap пишет пары в порядке закодированных ключей, чтобы одинаковые
+// map давали одинаковые байты (важно для Dedupe и Deterministic).
+func appendMsgpackMap(b []byte, v reflect.Value) ([]byte, error) {
+	type pair struct{ k, v []byte }
+	pairs := make([]pair, 0, v.Len())
+	for it := v.MapRange(); it.Next(); {
+		k, err := appendMsgpack(nil, it.Key())
+		if err != nil {
+			return nil, err
+		}
+		val, err := appendMsgpack(nil, it.Value())
+		if err != nil {
+			return nil, err
+		}
+		pairs = append(pairs, pair{k, val})
+	}
+	sort.Slice(pairs, func(i, j int) bool { return bytes.Compare(pairs[i].k, pairs[j].k) < 0 })
+
+	b, err := appendMsgpackHeader(b, len(pairs), 0x80, 16, 0, 0xde, 0xdf)
+	for _, p := range pairs {
+		b = append(append(b, p.k...), p.v...)
+	}
+	return b, err
+}
+
+var errMsgpackShort = errors.New("msgpack: неожиданный конец данных")
+
+type msgpackDecoder struct {
+	data []byte
+	pos  int
+}
+
+func (d *msgpackDecoder) next() (byte, error) {
+	if d.pos >= len(d.data) {
+		return 0, errMsgpackShort
+	}
+	d.pos++
+	return d.data[d.pos-1], nil
+}
+
+func (d *msgpackDecoder) read(n int) ([]byte, error) {
+	if n < 0 || n > len(d.data)-d.pos {
+		return nil, errMsgpackShort
+	}
+	d.pos += n
+	return d.data[d.pos-n : d.pos], nil
+}
+
+// uint читает беззнаковое big-endian число из size байт.
+func (d *msgpackDecoder) uint(size int) (uint64, error) {
+	p, err := d.read(size)
+	if err != nil {
+		return 0, err
+	}
+	var u uint64
+	for _, c := range p {
+		u = u<<8 | uint64(c)
+	}
+	return u, nil
+}
+
+// number разбирает целое по коду c. Для отрицательных значений neg = true,
+// а u хранит их в дополнительном коде. ok = false, если c - не целое.
+func (d *msgpackDecoder) number(c byte) (u uint64, neg, ok bool, err error) {
+	switch {
+	case c < 0x80:
+		return uint64(c), false, true, nil
+	case c >= 0xe0:
+		return uint64(int64(int8(c))), true, true, nil
+	case c >= 0xcc && c <= 0xcf:
+		u, err = d.uint(1 << (c - 0xcc))
+		return u, false, true, err
+	case c >= 0xd0 && c <= 0xd3:
+		size := 1 << (c - 0xd0)
+		u, err = d.uint(size)
+		// Расширяем знак до 64 бит.
+		shift := 64 - 8*size
+		i := int64(u<<shift) >> shift
+		return uint64(i), i < 0, true, err
+	}
+	return 0, false, false, nil
+}
+
+// length разбирает заголовок строки ('s'), двоичных данных ('b'), массива ('a')
+// или map ('m') по коду c. kind = 0, если c - не заголовок.
+func (d *msgpackDecoder) length(c byte) (n int, kind byte, err error) {
+	var u uint64
+	switch {
+	case c >= 0xa0 && c <= 0xbf:
+		u, kind = uint64(c&0x1f), 's'
+	case c >= 0x90 && c <= 0x9f:
+		u, kind = uint64(c&0x0f), 'a'
+	case c >= 0x80 && c <= 0x8f:
+		u, kind = uint64(c&0x0f), 'm'
+	case c == 0xd9 || c == 0xda || c == 0xdb:
+		kind = 's'
+		u, err = d.uint(1 << (c - 0xd9))
+	case c == 0xc4 || c == 0xc5 || c == 0xc6:
+		kind = 'b'
+		u, err = d.uint(1 << (c - 0xc4))
+	case c == 0xdc || c == 0xdd:
+		kind = 'a'
+		u, err = d.uint(2 << (c - 0xdc))
+	case c == 0xde || c == 0xdf:
+		kind = 'm'
+		u, err = d.uint(2 << (c - 0xde))
+	default:
+		return 0, 0, nil
+	}
+	// Каждый байт строки и каждый элемент массива или map занимают хотя бы байт:
+	// длина больше остатка данных означает повреждение, а не повод выделять память.
+	if err == nil && u > uint64(len(d.data)-d.pos) {
+		err = errMsgpackShort
+	}
+	return int(u), kind, err
+}
+
+// header читает заголовок одного из видов kinds.
+func (d *msgpackDecoder) header(kinds string, t reflect.Type) (int, byte, error) {
+	c, err := d.next()
+	if err != nil {
+		return 0, 0, err
+	}
+	n, kind, err := d.length(c)
+	if err != nil {
+		return 0, 0, err
+	}
+	if kind == 0 || !strings.ContainsRune(kinds, rune(kind)) {
+		return 0, 0, d.mismatch(c, t)
+	}
+	return n, kind, nil
+}
+
+func (d *msgpackDecoder) mismatch(c byte, t reflect.Type) error {
+	return fmt.Errorf("msgpack: код 0x%02x не подходит для типа %s", c, t)
+}
+
+func (d *msgpackDecoder) decode(v reflect.Value) error {
+	if d.pos >= len(d.data) {
+		return errMsgpackShort
+	}
+	if d.data[d.pos] == 0xc0 {
+		d.pos++
+		v.SetZero()
+		return nil
+	}
+	if v.Kind() != reflect.Pointer && v.Kind() != reflect.Interface && binaryType(v.Type()) {
+		n, _, err := d.header("b", v.Type())
+		if err != nil {
+			return err
+		}
+		data, _ := d.read(n)
+		return v.Addr().Interface().(encoding.BinaryUnmarshaler).UnmarshalBinary(data)
+	}
+
+	switch v.Kind() {
+	case reflect.Pointer:
+		if v.IsNil() {
+			v.Set(reflect.New(v.Type().Elem()))
+		}
+		return d.decode(v.Elem())
+	case reflect.Interface:
+		if v.NumMethod() != 0 {
+			return fmt.Errorf("msgpack: тип %s не поддерживается", v.Type())
+		}
+		x, err := d.any()
+		if err != nil {
+			return err
+		}
+		if x == nil {
+			v.SetZero()
+		} else {
+			v.Set(reflect.ValueOf(x))
+		}
+		return nil
+	case reflect.Bool:
+		c, _ := d.next()
+		if c != 0xc2 && c != 0xc3 {
+			return d.mismatch(c, v.Type())
+		}
+		v.SetBool(c == 0xc3)
+		return nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		c, _ := d.next()
+		u, neg, ok, err := d.number(c)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return d.mismatch(c, v.Type())
+		}
+		if !neg && u > math.MaxInt64 || v.OverflowInt(int64(u)) {
+			return fmt.Errorf("msgpack: значение не помещается в %s", v.Type())
+		}
+		v.SetInt(int64(u))
+		return nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		c, _ := d.next()
+		u, neg, ok, err := d.number(c)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return d.mismatch(c, v.Type())
+		}
+		if neg || v.OverflowUint(u) {
+			return fmt.Errorf("msgpack: значение не помещается в %s", v.Type())
+		}
+		v.SetUint(u)
+		return nil
+	case reflect.Float32, reflect.Float64:
+		c, _ := d.next()
+		switch c {
+		case 0xca:
+			u, err := d.uint(4)
+			v.SetFloat(float64(math.Float32frombits(uint32(u))))
+			return err
+		case 0xcb:
+			u, err := d.uint(8)
+			v.SetFloat(math.Float64frombits(u))
+			return err
+		}
+		u, neg, ok, err := d.number(c)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return d.mismatch(c, v.Type())
+		}
+		if neg {
+			v.SetFloat(float64(int64(u)))
+		} else {
+			v.SetFloat(float64(u))
+		}
+		return nil
+	case reflect.String:
+		n, _, err := d.header("sb", v.Type())
+		if err != nil {
+			return err
+		}
+		data, _ := d.read(n)
+		v.SetString(string(data))
+		return nil
+	case reflect.Slice:
+		if v.Type().Elem().Kind() == reflect.Uint8 {
+			n, _, err := d.header("bs", v.Type())
+			if err != nil {
+				return err
+			}
+			data, _ := d.read(n)
+			v.SetBytes(append(make([]byte, 0, n), data...))
+			return nil
+		}
+		n, _, err := d.header("a", v.Type())
+		if err != nil {
+			return err
+		}
+		v.Set(reflect.MakeSlice(v.Type(), n, n))
+		for i := range n {
+			if err := d.decode(v.Index(i)); err != nil {
+				return err
+			}
+		}
+		return nil
+	case reflect.Array:
+		kinds := "a"
+		if v.Type().Elem().Kind() == reflect.Uint8 {
+			kinds = "b"
+		}
+		n, kind, err := d.header(kinds, v.Type())
+		if err != nil {
+			return err
+		}
+		if n != v.Len() {
+			return fmt.Errorf("msgpack: %d элементов для типа %s", n, v.Type())
+		}
+		if kind == 'b' {
+			data, _ := d.read(n)
+			reflect.Copy(v, reflect.ValueOf(data))
+			return nil
+		}
+		for i := range n {
+			if err := d.decode(v.Index(i)); err != nil {
+				return err
+			}
+		}
+		return nil
+	case reflect.Map:
+		n, _, err := d.header("m", v.Type())
+		if err != nil {
+			return err
+		}
+		t := v.Type()
+		v.Set(reflect.MakeMapWithSize(t, n))
+		for range n {
+			k := reflect.New(t.Key()).Elem()
+			if err := d.decode(k); err != nil {
+				return err
+			}
+			e := reflect.New(t.Elem()).Elem()
+			if err := d.decode(e); err != nil {
+				return err
+			}
+			v.SetMapIndex(k, e)
+		}
+		return nil
+	case reflect.Struct:
+		n, _, err := d.header("m", v.Type())
+		if err != nil {
+			return err
+		}
+		fields := msgpackFields(v.Type())
+		for range n {
+			kn, _, err := d.header("s", v.Type())
+			if err != nil {
+				return err
+			}
+			name, _ := d.read(kn)
+			var field *msgpackField
+			for i := range fields {
+				if fields[i].name == string(name) {
+					field = &fields[i]
+					break
+				}
+			}
+			if field == nil {
+				// Поле удалено из типа: пропускаем значение.
+				if err := d.skip(); err != nil {
+					return err
+				}
+				continue
+			}
+			if err := d.decode(fieldAlloc(v, field.index)); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	return fmt.Errorf("msgpack: тип %s не поддерживается", v.Type())
+}
+
+// any читает значение без известного типа: nil, bool, int64 или uint64 (больше
+// MaxInt64), float32, float64, string, []byte, []any или map[string]any.
+func (d *msgpackDecoder) any() (any, error) {
+	c, err := d.next()
+	if err != nil {
+		return nil, err
+	}
+	switch c {
+	case 0xc0:
+		return nil, nil
+	case 0xc2, 0xc3:
+		return c == 0xc3, nil
+	case 0xca:
+		u, err := d.uint(4)
+		return math.Float32frombits(uint32(u)), err
+	case 0xcb:
+		u, err := d.uint(8)
+		return math.Float64frombits(u), err
+	}
+	if u, neg, ok, err := d.number(c); ok {
+		if neg || u <= math.MaxInt64 {
+			return int64(u), err
+		}
+		return u, err
+	}
+
+	n, kind, err := d.length(c)
+	if err != nil {
+		return nil, err
+	}
+	switch kind {
+	case 's':
+		data, _ := d.read(n)
+		return string(data), nil
+	case 'b':
+		data, _ := d.read(n)
+		return append([]byte{}, data...), nil
+	case 'a':
+		arr := make([]any, n)
+		for i := range arr {
+			if arr[i], err = d.any(); err != nil {
+				return nil, err
+			}
+		}
+		return arr, nil
+	case 'm':
+		m := make(map[string]any, n)
+		for range n {
+			k, err := d.any()
+			if err != nil {
+				return nil, err
+			}
+			ks, ok := k.(string)
+			if !ok {
+				return nil, fmt.Errorf("msgpack: ключ %T в map без типа", k)
+			}
+			if m[ks], err = d.any(); err != nil {
+				return nil, err
+			}
+		}
+		return m, nil
+	}
+	return nil, fmt.Errorf("msgpack: неподдерживаемый код 0x%02x", c)
+}
+
+// fieldAlloc возвращает поле по пути index, создавая встроенные структуры по nil-указателям.
+func fieldAlloc(v reflect.Value, index []int) reflect.Value {
+	for i, x := range index {
+		if i > 0 && v.Kind() == reflect.Pointer {
+			if v.IsNil() {
+				v.Set(reflect.New(v.Type().Elem()))
+			}
+			v = v.Elem()
+		}
+		v = v.Field(x)
+	}
+	return v
+}
+
+// skip пропускает значение, не разбирая его.
+func (d *msgpackDecoder) skip() error {
+	c, err := d.next()
+	if err != nil {
+		return err
+	}
+	switch c {
+	case 0xc0, 0xc2, 0xc3:
+		return nil
+	case 0xca:
+		_, err = d.read(4)
+		return err
+	case 0xcb:
+		_, err = d.read(8)
+		return err
+	}
+	if _, _, ok, err := d.number(c); ok {
+		return err
+	}
+	n, kind, err := d.length(c)
+	if err != nil {
+		return err
+	}
+	switch kind {
+	case 's', 'b':
+		_, err = d.read(n)
+		return err
+	case 'm':
+		n *= 2
+		fallthrough
+	case 'a':
+		for range n {
+			if err := d.skip(); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	return fmt.Errorf("msgpack: неподдерживаемый код 0x%02x", c)
+}
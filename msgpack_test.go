@@ -0,0 +1,124 @@
+package qwick
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestMsgpackCodec(t *testing.T) {
+	type v1 struct {
+		ID    uint64
+		Name  string
+		Tags  []string
+		Flags map[int]bool
+		Skip  string `msgpack:"-"`
+	}
+	type v2 struct {
+		ID    uint64
+		Title string `msgpack:"Name"`
+		Tags  []string
+		Score float32
+	}
+
+	in := v1{ID: 7, Name: "qwick", Tags: []string{"a", "b"}, Flags: map[int]bool{-1: true, 300: false}, Skip: "x"}
+	data, err := MsgpackCodec[v1]{}.Encode(in)
+	if err != nil {
+		t.Fatalf("Ошибка Encode: %v", err)
+	}
+	js, _ := json.Marshal(in)
+	if len(data) >= len(js) {
+		t.Errorf("msgpack (%d байт) не компактнее JSON (%d байт)", len(data), len(js))
+	}
+
+	// Данные описывают себя: читаются в изменённый тип, лишние поля пропускаются.
+	got, err := MsgpackCodec[v2]{}.Decode(data)
+	want := v2{ID: 7, Title: "qwick", Tags: []string{"a", "b"}}
+	if err != nil || !reflect.DeepEqual(got, want) {
+		t.Errorf("Decode в новый тип: получено %+v, err %v", got, err)
+	}
+	back, err := MsgpackCodec[v1]{}.Decode(data)
+	in.Skip = ""
+	if err != nil || !reflect.DeepEqual(back, in) {
+		t.Errorf("Decode: получено %+v, err %v", back, err)
+	}
+
+	// Порядок ключей map не влияет на байты.
+	again, _ := MsgpackCodec[v1]{}.Encode(in)
+	if !bytes.Equal(again, data) {
+		t.Error("Кодирование map недетерминировано")
+	}
+
+	anyVal, err := MsgpackCodec[any]{}.Decode(mustMsgpack(t, map[string]any{"n": -3, "s": "x", "l": []any{true, nil, 1.5}}))
+	wantAny := map[string]any{"n": int64(-3), "s": "x", "l": []any{true, nil, 1.5}}
+	if err != nil || !reflect.DeepEqual(anyVal, wantAny) {
+		t.Errorf("Decode в any: получено %#v, err %v", anyVal, err)
+	}
+
+	for _, n := range []int64{0, 127, 128, -32, -33, math.MinInt8 - 1, math.MaxInt16 + 1, math.MinInt32 - 1, math.MaxInt64, math.MinInt64} {
+		got, err := MsgpackCodec[int64]{}.Decode(mustMsgpack(t, n))
+		if err != nil || got != n {
+			t.Errorf("int64 %d: получено %d, err %v", n, got, err)
+		}
+	}
+	if _, err := (MsgpackCodec[int8]{}).Decode(mustMsgpack(t, 300)); err == nil {
+		t.Error("Ожидалась ошибка переполнения int8")
+	}
+	if _, err := (MsgpackCodec[uint]{}).Decode(mustMsgpack(t, -1)); err == nil {
+		t.Error("Ожидалась ошибка для отрицательного uint")
+	}
+	if _, err := (MsgpackCodec[string]{}).Decode(mustMsgpack(t, 1)); err == nil {
+		t.Error("Ожидалась ошибка несовпадения типа")
+	}
+	for i := 1; i < len(data); i++ {
+		if _, err := (MsgpackCodec[v1]{}).Decode(data[:i]); err == nil {
+			t.Fatalf("Ожидалась ошибка для обрезанных данных (%d байт)", i)
+		}
+	}
+	if _, err := (MsgpackCodec[v1]{}).Decode(append(data, 0)); err == nil {
+		t.Error("Ожидалась ошибка для лишних байт")
+	}
+	if _, err := (MsgpackCodec[func()]{}).Encode(func() {}); err == nil {
+		t.Error("Ожидалась ошибка для функции")
+	}
+}
+
+func mustMsgpack[T any](t *testing.T, v T) []byte {
+	t.Helper()
+	data, err := MsgpackCodec[T]{}.Encode(v)
+	if err != nil {
+		t.Fatalf("Ошибка Encode: %v", err)
+	}
+	return data
+}
+
+func TestMsgpackTyped(t *testing.T) {
+	type doc struct {
+		Title string
+		Words []string
+	}
+	tb := NewTypedBuilder[uint64, doc](Uint64Key{}, MsgpackCodec[doc]{})
+	for i := range uint64(100) {
+		if err := tb.Put(i, doc{Title: fmt.Sprint("doc", i), Words: []string{"w", fmt.Sprint(i)}}); err != nil {
+			t.Fatalf("Ошибка Put: %v", err)
+		}
+	}
+	path := filepath.Join(t.TempDir(), "msgpack.qwick")
+	if err := tb.Build(path, BuildOptions{Compression: compZstd}); err != nil {
+		t.Fatalf("Ошибка Build: %v", err)
+	}
+	db, err := Open(path)
+	if err != nil {
+		t.Fatalf("Ошибка Open: %v", err)
+	}
+	defer db.Close()
+
+	d, ok, err := NewTyped[uint64, doc](db, Uint64Key{}, MsgpackCodec[doc]{}).Get(42)
+	if err != nil || !ok || d.Title != "doc42" || !reflect.DeepEqual(d.Words, []string{"w", "42"}) {
+		t.Errorf("Get: %+v, %v, %v", d, ok, err)
+	}
+}
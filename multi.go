@@ -0,0 +1,118 @@
+package qwick
+
+import (
+	"bytes"
+	"errors"
+	"slices"
+)
+
+// Values - несколько значений одного ключа. Вставленные в дерево как значение,
+// они записываются соседними записями индекса с одинаковым ключом; с
+// BuildOptions.SortValues - по возрастанию байт.
+type Values [][]byte
+
+// addValues дописывает все значения ключа подряд.
+func (b *builder) addValues(key []byte, vals Values) error {
+	if b.opts.SortValues {
+		vals = slices.Clone(vals)
+		slices.SortFunc(vals, bytes.Compare)
+	}
+	for _, v := range vals {
+		if err := b.add(key, v); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// keyRange возвращает диапазон записей [lo, hi) с ключом key.
+func (db *MMAPDB) keyRange(key []byte) (lo, hi uint64) {
+	lo, ok := db.findIndex(key)
+	if !ok {
+		return lo, lo
+	}
+	if db.hdr.Flags&flagDups == 0 {
+		return lo, lo + 1
+	}
+	hi = db.num
+	for l := lo + 1; l < hi; {
+		mid := (l + hi) >> 1
+		if k := db.getKeySlice(mid); k != nil && bytes.Equal(k, key) {
+			l = mid + 1
+		} else {
+			hi = mid
+		}
+	}
+	return lo, hi
+}
+
+// GetAll вызывает cb для каждого значения ключа в порядке записей (распакованные данные).
+// Значение действительно только во время вызова.
+func (db *MMAPDB) GetAll(key []byte, cb func(val []byte) bool) error {
+	lo, hi := db.keyRange(key)
+	for i := lo; i < hi; i++ {
+		if db.hidden(i) {
+			continue
+		}
+		val, err := db.value(i)
+		if err != nil {
+			return err
+		}
+		if !cb(val) {
+			return nil
+		}
+	}
+	return nil
+}
+
+// Count возвращает число значений ключа.
+func (db *MMAPDB) Count(key []byte) uint64 {
+	lo, hi := db.keyRange(key)
+	return hi - lo - db.hiddenIn(lo, hi)
+}
+
+// Intersect вызывает cb для значений, которые есть и у ключа a, и у ключа b
+// (пересечение отсортированных множеств), в порядке возрастания. Требует файла,
+// собранного с SortValues.
+func (db *MMAPDB) Intersect(a, b []byte, cb func(val []byte) bool) error {
+	if db.hdr.Flags&flagDups != 0 && db.hdr.Flags&flagSortedValues == 0 {
+		return errors.New("значения не отсортированы: соберите файл с SortValues")
+	}
+	i, iEnd := db.keyRange(a)
+	j, jEnd := db.keyRange(b)
+	var va, vb []byte
+	for i < iEnd && j < jEnd {
+		if db.hidden(i) {
+			i, va = i+1, nil
+			continue
+		}
+		if db.hidden(j) {
+			j, vb = j+1, nil
+			continue
+		}
+		var err error
+		if va == nil {
+			if va, err = db.value(i); err != nil {
+				return err
+			}
+		}
+		if vb == nil {
+			if vb, err = db.value(j); err != nil {
+				return err
+			}
+		}
+		switch c := bytes.Compare(va, vb); {
+		case c < 0:
+			i, va = i+1, nil
+		case c > 0:
+			j, vb = j+1, nil
+		default:
+			if !cb(va) {
+				return nil
+			}
+			i, va = i+1, nil
+			j, vb = j+1, nil
+		}
+	}
+	return nil
+}
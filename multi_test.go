@@ -0,0 +1,119 @@
+package qwick
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func itemIDs(ids ...int) Values {
+	vals := make(Values, len(ids))
+	for i, id := range ids {
+		vals[i] = []byte(fmt.Sprintf("item%03d", id))
+	}
+	return vals
+}
+
+func TestMultiValues(t *testing.T) {
+	tmpDir, _ := os.MkdirTemp("", "qwick_multi")
+	defer os.RemoveAll(tmpDir)
+
+	for _, opts := range []BuildOptions{
+		{Compression: compZstd, SortValues: true},
+		{Layout: LayoutBlocks, BlockSize: 64, SortValues: true},
+		{ZstdLevel: 1, SizeCutover: 256},
+	} {
+		tree := New()
+		tree.Insert([]byte("tag:db"), itemIDs(5, 1, 9, 3, 7))
+		tree.Insert([]byte("tag:go"), itemIDs(2, 3, 4, 9, 8))
+		tree.Insert([]byte("tag:one"), itemIDs(1))
+		tree.Insert([]byte("z"), []byte("plain"))
+		path := filepath.Join(tmpDir, "multi.qwick")
+		if err := BuildWithOptions(tree, path, opts); err != nil {
+			t.Fatalf("Ошибка BuildWithOptions: %v", err)
+		}
+		db, err := Open(path)
+		if err != nil {
+			t.Fatalf("Ошибка Open: %v", err)
+		}
+
+		var got []string
+		err = db.GetAll([]byte("tag:db"), func(v []byte) bool {
+			got = append(got, string(v))
+			return true
+		})
+		want := "[item005 item001 item009 item003 item007]"
+		if opts.SortValues {
+			want = "[item001 item003 item005 item007 item009]"
+		}
+		if err != nil || fmt.Sprint(got) != want {
+			t.Errorf("GetAll: %v, err %v", got, err)
+		}
+
+		for key, n := range map[string]uint64{"tag:db": 5, "tag:go": 5, "tag:one": 1, "z": 1, "tag": 0, "zz": 0} {
+			if c := db.Count([]byte(key)); c != n {
+				t.Errorf("Count(%s) = %d, ожидалось %d", key, c, n)
+			}
+		}
+
+		// Find возвращает первое значение ключа.
+		if v, ok, _ := db.Find([]byte("tag:go"), nil); !ok || (opts.SortValues && string(v) != "item002") {
+			t.Errorf("Find: %s, %v", v, ok)
+		}
+
+		got = got[:0]
+		err = db.Intersect([]byte("tag:db"), []byte("tag:go"), func(v []byte) bool {
+			got = append(got, string(v))
+			return true
+		})
+		if opts.SortValues {
+			if err != nil || fmt.Sprint(got) != "[item003 item009]" {
+				t.Errorf("Intersect: %v, err %v", got, err)
+			}
+		} else if err == nil {
+			t.Error("Intersect: ожидалась ошибка для неотсортированных значений")
+		}
+
+		n := 0
+		db.PrefixRaw([]byte("tag:"), func(k, v []byte) bool { n++; return true })
+		if n != 11 {
+			t.Errorf("PrefixRaw: %d записей, ожидалось 11", n)
+		}
+		db.Close()
+	}
+}
+
+func TestMultiValuesStream(t *testing.T) {
+	tmpDir, _ := os.MkdirTemp("", "qwick_multi_stream")
+	defer os.RemoveAll(tmpDir)
+	path := filepath.Join(tmpDir, "ns.qwick")
+
+	specs := []NamespaceSpec{{
+		Name:    "tags",
+		Entries: pairs("a", "1", "a", "2", "b", "2"),
+		Options: BuildOptions{SortValues: true},
+	}}
+	if err := BuildNamespaces(path, specs, BuildOptions{}); err != nil {
+		t.Fatalf("Ошибка BuildNamespaces: %v", err)
+	}
+	db, err := Open(path)
+	if err != nil {
+		t.Fatalf("Ошибка Open: %v", err)
+	}
+	defer db.Close()
+	tags := db.Namespace("tags")
+	var got []string
+	tags.Intersect([]byte("a"), []byte("b"), func(v []byte) bool {
+		got = append(got, string(v))
+		return true
+	})
+	if fmt.Sprint(got) != "[2]" || tags.Count([]byte("a")) != 2 {
+		t.Errorf("Intersect: %v, Count %d", got, tags.Count([]byte("a")))
+	}
+
+	specs[0].Entries = pairs("a", "2", "a", "1")
+	if err := BuildNamespaces(path, specs, BuildOptions{}); err == nil {
+		t.Error("Ожидалась ошибка для неотсортированных значений потока")
+	}
+}
@@ -0,0 +1,202 @@
+package qwick
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"iter"
+	"sort"
+	"time"
+
+	art "github.com/plar/go-adaptive-radix-tree/v2"
+)
+
+// NamespaceSpec описывает одно пространство имён общего файла. Источник записей -
+// либо Tree, либо Entries: поток пар в порядке неубывания ключей (повторяющиеся
+// ключи идут подряд).
+type NamespaceSpec struct {
+	Name    string
+	Tree    art.Tree
+	Entries iter.Seq2[[]byte, []byte]
+	Options BuildOptions // сжатие, раскладка, индексы и т.п. этого пространства
+}
+
+// spaceEntry - имя пространства и его заголовок (смещения абсолютные).
+type spaceEntry struct {
+	name string
+	hdr  fileHeader
+}
+
+// BuildNamespaces собирает в path файл из нескольких независимо отсортированных
+// пространств имён с общим заголовком и общим хэшем содержимого. opts относятся
+// к файлу в целом (Deterministic, Metadata); сжатие задаётся для каждого пространства.
+func BuildNamespaces(path string, specs []NamespaceSpec, opts BuildOptions) error {
+	if err := validateSpecs(specs); err != nil {
+		return err
+	}
+	return buildFile(path, opts, nil, fillSpaces(specs))
+}
+
+// BuildNamespacesTo пишет файл с пространствами имён в w (см. BuildTo).
+func BuildNamespacesTo(w io.Writer, specs []NamespaceSpec, opts BuildOptions) error {
+	if err := validateSpecs(specs); err != nil {
+		return err
+	}
+	return buildTo(w, opts, nil, fillSpaces(specs))
+}
+
+func validateSpecs(specs []NamespaceSpec) error {
+	seen := make(map[string]bool, len(specs))
+	for _, spec := range specs {
+		if spec.Name == "" {
+			return errors.New("пустое имя пространства имён")
+		}
+		if seen[spec.Name] {
+			return fmt.Errorf("пространство имён %q объявлено дважды", spec.Name)
+		}
+		seen[spec.Name] = true
+		if (spec.Tree == nil) == (spec.Entries == nil) {
+			return fmt.Errorf("пространство имён %q: нужен ровно один источник - Tree или Entries", spec.Name)
+		}
+		if err := spec.Options.validate(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func fillSpaces(specs []NamespaceSpec) func(b *builder) error {
+	return func(b *builder) error {
+		for _, spec := range specs {
+			if err := b.addSpace(spec); err != nil {
+				return fmt.Errorf("пространство имён %q: %w", spec.Name, err)
+			}
+		}
+		return nil
+	}
+}
+
+// addSpace пишет пространство имён вложенным сборщиком в тот же поток.
+func (b *builder) addSpace(spec NamespaceSpec) error {
+	opts := spec.Options
+	opts.Deterministic = opts.Deterministic || b.opts.Deterministic
+	nb := &builder{w: b.w, off: b.off, opts: opts, hash: b.hash, nested: true}
+	defer nb.close()
+
+	if err := nb.init(spec.Tree); err != nil {
+		return err
+	}
+	fill := fillTree(spec.Tree)
+	if spec.Tree == nil {
+		fill = fillEntries(spec.Entries)
+	}
+	start := time.Now()
+	if err := fill(nb); err != nil {
+		return err
+	}
+	hdr, err := nb.finish()
+	if err != nil {
+		return err
+	}
+	if opts.Report != nil {
+		*opts.Report = BuildReport{Stats: nb.stats, Fill: time.Since(start), Total: time.Since(start)}
+	}
+	b.stats.merge(nb.stats)
+	b.off = nb.off
+	b.spaces = append(b.spaces, spaceEntry{spec.Name, hdr})
+	return nil
+}
+
+// fillEntries передаёт сборщику поток пар, проверяя порядок ключей.
+func fillEntries(entries iter.Seq2[[]byte, []byte]) func(b *builder) error {
+	return func(b *builder) error {
+		var prev, prevVal []byte
+		for k, v := range entries {
+			c := 1
+			if prev != nil {
+				c = bytes.Compare(k, prev)
+			}
+			if c < 0 {
+				return fmt.Errorf("ключи потока не упорядочены: %q после %q", k, prev)
+			}
+			// Повторяющийся ключ: значения должны идти в порядке SortValues.
+			if c == 0 && b.opts.SortValues && bytes.Compare(v, prevVal) < 0 {
+				return fmt.Errorf("значения ключа %q не отсортированы", k)
+			}
+			prev = append(prev[:0], k...)
+			prevVal = append(prevVal[:0], v...)
+			if err := b.add(k, v); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+}
+
+// encodeSpaces сериализует таблицу пространств: Count(4), затем для каждого
+// NameLen(4) + Name + заголовок (headerSize байт).
+func encodeSpaces(spaces []spaceEntry) []byte {
+	buf := binary.LittleEndian.AppendUint32(nil, uint32(len(spaces)))
+	for _, sp := range spaces {
+		buf = binary.LittleEndian.AppendUint32(buf, uint32(len(sp.name)))
+		buf = append(buf, sp.name...)
+		buf = append(buf, sp.hdr.encode()...)
+	}
+	return buf
+}
+
+// loadSpaces открывает пространства имён поверх источника данных файла.
+func (db *MMAPDB) loadSpaces(t []byte, opts OpenOptions) error {
+	bad := errors.New("некорректная таблица пространств имён")
+	if len(t) < 4 {
+		return bad
+	}
+	count := binary.LittleEndian.Uint32(t)
+	t = t[4:]
+	db.spaces = make(map[string]*MMAPDB, min(count, 1024))
+	for range count {
+		if len(t) < 4 {
+			return bad
+		}
+		n := uint64(binary.LittleEndian.Uint32(t))
+		if n+4+headerSize > uint64(len(t)) {
+			return bad
+		}
+		name := string(t[4 : 4+n])
+		hdr, err := parseHeader(t[4+n : 4+n+headerSize])
+		if err != nil {
+			return err
+		}
+		t = t[4+n+headerSize:]
+
+		ns := &MMAPDB{mdata: db.mdata, size: db.size, ra: db.ra}
+		if err := ns.setup(hdr, opts); err != nil {
+			return fmt.Errorf("пространство имён %q: %w", name, err)
+		}
+		if h := db.section(secHash); h != nil {
+			sum := sha256.Sum256(append(append(bytes.Clone(h), 0), name...))
+			ns.spaceHash = sum[:]
+		}
+		db.spaces[name] = ns
+	}
+	return nil
+}
+
+// Namespace возвращает пространство имён name с полным API чтения или nil, если
+// его нет. Пространство использует данные файла и закрывается вместе с ним.
+func (db *MMAPDB) Namespace(name string) *MMAPDB {
+	return db.spaces[name]
+}
+
+// Namespaces возвращает имена пространств имён файла.
+func (db *MMAPDB) Namespaces() []string {
+	names := make([]string, 0, len(db.spaces))
+	for name := range db.spaces {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,168 @@
+package qwick
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	art "github.com/plar/go-adaptive-radix-tree/v2"
+)
+
+func pairs(kv ...string) func(yield func(k, v []byte) bool) {
+	return func(yield func(k, v []byte) bool) {
+		for i := 0; i+1 < len(kv); i += 2 {
+			if !yield([]byte(kv[i]), []byte(kv[i+1])) {
+				return
+			}
+		}
+	}
+}
+
+func testSpecs() []NamespaceSpec {
+	return []NamespaceSpec{
+		{Name: "users", Tree: jsonTree(200), Options: BuildOptions{Compression: compZstd}},
+		{Name: "plans", Entries: pairs("enterprise", "3", "free", "1", "pro", "2"), Options: BuildOptions{Compression: compS2}},
+		{Name: "geo", Entries: pairs("AM", "Армения", "BY", "Беларусь", "RU", "Россия"), Options: BuildOptions{Layout: LayoutBlocks}},
+	}
+}
+
+func checkSpaces(t *testing.T, db *MMAPDB) {
+	t.Helper()
+	if fmt.Sprint(db.Namespaces()) != "[geo plans users]" {
+		t.Errorf("Namespaces: %v", db.Namespaces())
+	}
+	if db.Namespace("flags") != nil {
+		t.Error("Найдено несуществующее пространство имён")
+	}
+
+	users := db.Namespace("users")
+	jsonTree(200).ForEach(func(n art.Node) bool {
+		val, ok, err := users.Find(n.Key(), nil)
+		if !ok || err != nil || !bytes.Equal(val, n.Value().([]byte)) {
+			t.Fatalf("users: ошибка Find %s: ok %v, err %v", n.Key(), ok, err)
+		}
+		return true
+	})
+	if v, ok := db.Namespace("plans").GetRaw([]byte("pro")); !ok || len(v) == 0 {
+		t.Error("plans: ключ pro не найден")
+	}
+	if _, ok := db.Namespace("plans").GetRaw([]byte("user:000001")); ok {
+		t.Error("Ключ другого пространства имён виден в plans")
+	}
+	if got := dumpDB(t, db.Namespace("geo")); got["RU"] != "Россия" || len(got) != 3 {
+		t.Errorf("geo: %v", got)
+	}
+	if got := dumpDB(t, db.Namespace("plans")); fmt.Sprint(got) != "map[enterprise:3 free:1 pro:2]" {
+		t.Errorf("plans: %v", got)
+	}
+}
+
+func TestNamespaces(t *testing.T) {
+	tmpDir, _ := os.MkdirTemp("", "qwick_ns")
+	defer os.RemoveAll(tmpDir)
+
+	path := filepath.Join(tmpDir, "release.qwick")
+	opts := BuildOptions{Deterministic: true, Metadata: map[string][]byte{"release": []byte("42")}}
+	if err := BuildNamespaces(path, testSpecs(), opts); err != nil {
+		t.Fatalf("Ошибка BuildNamespaces: %v", err)
+	}
+	db, err := Open(path)
+	if err != nil {
+		t.Fatalf("Ошибка Open: %v", err)
+	}
+	defer db.Close()
+	checkSpaces(t, db)
+	if string(db.Metadata()["release"]) != "42" || db.ContentHash() == nil {
+		t.Error("Метаданные и хэш должны быть общими для файла")
+	}
+
+	// Потоковая сборка: тот же файл с заголовком в конце и тот же хэш.
+	var buf bytes.Buffer
+	if err := BuildNamespacesTo(&buf, testSpecs(), opts); err != nil {
+		t.Fatalf("Ошибка BuildNamespacesTo: %v", err)
+	}
+	sdb, err := OpenBytes(buf.Bytes())
+	if err != nil {
+		t.Fatalf("Ошибка OpenBytes: %v", err)
+	}
+	defer sdb.Close()
+	checkSpaces(t, sdb)
+	if !bytes.Equal(sdb.ContentHash(), db.ContentHash()) {
+		t.Error("Хэш потокового файла отличается")
+	}
+}
+
+func TestNamespacesErrors(t *testing.T) {
+	tmpDir, _ := os.MkdirTemp("", "qwick_ns_err")
+	defer os.RemoveAll(tmpDir)
+	path := filepath.Join(tmpDir, "bad.qwick")
+
+	for name, specs := range map[string][]NamespaceSpec{
+		"dup":      {{Name: "a", Entries: pairs()}, {Name: "a", Entries: pairs()}},
+		"empty":    {{Entries: pairs()}},
+		"source":   {{Name: "a"}},
+		"unsorted": {{Name: "a", Entries: pairs("b", "1", "a", "2")}},
+		"layout":   {{Name: "a", Entries: pairs(), Options: BuildOptions{Layout: 7}}},
+	} {
+		if err := BuildNamespaces(path, specs, BuildOptions{}); err == nil {
+			t.Errorf("%s: ожидалась ошибка", name)
+		}
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Error("Файл не должен создаваться при ошибке")
+	}
+}
+
+func TestNamespacesMergeDiff(t *testing.T) {
+	tmpDir := t.TempDir()
+	paths := []string{filepath.Join(tmpDir, "a.qwick"), filepath.Join(tmpDir, "b.qwick")}
+	var dbs []*MMAPDB
+	for _, path := range paths {
+		if err := BuildNamespaces(path, testSpecs(), BuildOptions{}); err != nil {
+			t.Fatalf("Ошибка BuildNamespaces: %v", err)
+		}
+		db, err := Open(path)
+		if err != nil {
+			t.Fatalf("Ошибка Open: %v", err)
+		}
+		defer db.Close()
+		dbs = append(dbs, db)
+	}
+
+	// Корневой индекс пуст: без ошибки получился бы пустой результат.
+	out := filepath.Join(tmpDir, "out.qwick")
+	if err := Merge(out, dbs, LastWins); err != errSpacesSource {
+		t.Errorf("Merge: %v", err)
+	}
+	if err := Compact(out, NewOverlay(dbs...)); err != errSpacesSource {
+		t.Errorf("Compact: %v", err)
+	}
+	if err := Diff(dbs[0], dbs[1], func(Change) bool { return true }); err != errSpacesSource {
+		t.Errorf("Diff: %v", err)
+	}
+	if err := BuildDelta(dbs[0], dbs[1], out); err != errSpacesSource {
+		t.Errorf("BuildDelta: %v", err)
+	}
+	if _, err := os.Stat(out); !os.IsNotExist(err) {
+		t.Error("Файл не должен создаваться при ошибке")
+	}
+
+	// Отдельные пространства имён сливаются и сравниваются как обычные базы.
+	a, b := dbs[0].Namespace("plans"), dbs[1].Namespace("plans")
+	if err := Merge(out, []*MMAPDB{a, b}, LastWins); err != nil {
+		t.Fatalf("Merge пространств имён: %v", err)
+	}
+	merged, err := Open(out)
+	if err != nil {
+		t.Fatalf("Ошибка Open: %v", err)
+	}
+	defer merged.Close()
+	if got := dumpDB(t, merged); fmt.Sprint(got) != "map[enterprise:3 free:1 pro:2]" {
+		t.Errorf("Слияние plans: %v", got)
+	}
+	if err := Diff(a, b, func(c Change) bool { t.Errorf("Лишнее изменение %s", c.Key); return true }); err != nil {
+		t.Errorf("Diff пространств имён: %v", err)
+	}
+}
@@ -0,0 +1,199 @@
+package qwick
+
+import (
+	"expvar"
+	"fmt"
+	"io"
+	"net/http"
+	"sync/atomic"
+	"time"
+)
+
+// Op - операция чтения, о которой сообщается наблюдателю.
+type Op int
+
+const (
+	OpGetRaw Op = iota
+	OpFind
+	OpPrefixRaw
+	OpPrefix
+	OpRangeRaw
+	OpRange
+	numOps
+)
+
+var opNames = [numOps]string{"get_raw", "find", "prefix_raw", "prefix", "range_raw", "range"}
+
+func (op Op) String() string {
+	if op < 0 || op >= numOps {
+		return "unknown"
+	}
+	return opNames[op]
+}
+
+// Observer получает события чтения базы (OpenOptions.Observer). Методы вызываются
+// синхронно из читающих горутин и должны быть быстрыми и потокобезопасными.
+type Observer interface {
+	// OnLookup - поиск ключа (GetRaw, Find): найден ли ключ и сколько байт возвращено.
+	OnLookup(op Op, found bool, n int)
+	// OnScan - завершённый обход (Prefix, Range и их Raw-варианты).
+	OnScan(op Op, entries, n int)
+	// OnDecode - распаковка значения или блока: размер результата, время и ошибка.
+	OnDecode(n int, d time.Duration, err error)
+}
+
+// observeScan оборачивает колбэк обхода подсчётом записей и байт; done сообщает итог.
+func (db *MMAPDB) observeScan(op Op, cb func(key, val []byte) bool) (wrapped func(key, val []byte) bool, done func()) {
+	var entries, n int
+	wrapped = func(key, val []byte) bool {
+		entries++
+		n += len(val)
+		return cb(key, val)
+	}
+	return wrapped, func() { db.obs.OnScan(op, entries, n) }
+}
+
+// Metrics - готовый Observer на атомарных счётчиках. Публикуется через expvar
+// (Publish) или в текстовом формате Prometheus (MetricsHandler).
+type Metrics struct {
+	name string
+
+	hits         [numOps]atomic.Uint64
+	misses       [numOps]atomic.Uint64
+	scans        [numOps]atomic.Uint64
+	scanEntries  [numOps]atomic.Uint64
+	bytes        [numOps]atomic.Uint64
+	decodes      atomic.Uint64
+	decodeErrors atomic.Uint64
+	decodeNanos  atomic.Uint64
+	decodeBytes  atomic.Uint64
+}
+
+// NewMetrics создаёт счётчики базы; name попадает в метку db.
+func NewMetrics(name string) *Metrics {
+	return &Metrics{name: name}
+}
+
+func (m *Metrics) OnLookup(op Op, found bool, n int) {
+	if found {
+		m.hits[op].Add(1)
+	} else {
+		m.misses[op].Add(1)
+	}
+	m.bytes[op].Add(uint64(n))
+}
+
+func (m *Metrics) OnScan(op Op, entries, n int) {
+	m.scans[op].Add(1)
+	m.scanEntries[op].Add(uint64(entries))
+	m.bytes[op].Add(uint64(n))
+}
+
+func (m *Metrics) OnDecode(n int, d time.Duration, err error) {
+	m.decodes.Add(1)
+	m.decodeNanos.Add(uint64(d))
+	m.decodeBytes.Add(uint64(n))
+	if err != nil {
+		m.decodeErrors.Add(1)
+	}
+}
+
+// MetricsSnapshot - значения счётчиков на момент чтения. Ключи карт - имена операций.
+type MetricsSnapshot struct {
+	Hits          map[string]uint64
+	Misses        map[string]uint64
+	Scans         map[string]uint64
+	ScanEntries   map[string]uint64
+	Bytes         map[string]uint64
+	Decodes       uint64
+	DecodeErrors  uint64
+	DecodeSeconds float64
+	DecodeBytes   uint64
+}
+
+// Snapshot возвращает текущие значения счётчиков.
+func (m *Metrics) Snapshot() MetricsSnapshot {
+	return MetricsSnapshot{
+		Hits:          perOp(&m.hits),
+		Misses:        perOp(&m.misses),
+		Scans:         perOp(&m.scans),
+		ScanEntries:   perOp(&m.scanEntries),
+		Bytes:         perOp(&m.bytes),
+		Decodes:       m.decodes.Load(),
+		DecodeErrors:  m.decodeErrors.Load(),
+		DecodeSeconds: time.Duration(m.decodeNanos.Load()).Seconds(),
+		DecodeBytes:   m.decodeBytes.Load(),
+	}
+}
+
+func perOp(c *[numOps]atomic.Uint64) map[string]uint64 {
+	out := make(map[string]uint64)
+	for op := range numOps {
+		if v := c[op].Load(); v != 0 {
+			out[op.String()] = v
+		}
+	}
+	return out
+}
+
+// Publish публикует снимок счётчиков в expvar под именем name.
+// Как и expvar.Publish, паникует при повторном использовании имени.
+func (m *Metrics) Publish(name string) {
+	expvar.Publish(name, expvar.Func(func() any { return m.Snapshot() }))
+}
+
+// MetricsHandler отдаёт счётчики баз в текстовом формате Prometheus.
+func MetricsHandler(ms ...*Metrics) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		writeMetrics(w, ms)
+	})
+}
+
+// opSeries - счётчики по операциям с дополнительными метками Prometheus.
+type opSeries struct {
+	labels string
+	c      *[numOps]atomic.Uint64
+}
+
+// writeMetrics пишет счётчики в текстовом формате Prometheus.
+func writeMetrics(w io.Writer, ms []*Metrics) {
+	// family пишет счётчик по операциям; серии отличаются дополнительными метками.
+	family := func(name, help string, series func(m *Metrics) []opSeries) {
+		fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s counter\n", name, help, name)
+		for _, m := range ms {
+			for _, ser := range series(m) {
+				for op := range numOps {
+					if v := ser.c[op].Load(); v != 0 {
+						fmt.Fprintf(w, "%s{db=%q,op=%q%s} %d\n", name, m.name, op.String(), ser.labels, v)
+					}
+				}
+			}
+		}
+	}
+	family("qwick_lookups_total", "Key lookups by result.", func(m *Metrics) []opSeries {
+		return []opSeries{{`,result="hit"`, &m.hits}, {`,result="miss"`, &m.misses}}
+	})
+	family("qwick_scans_total", "Completed scans.", func(m *Metrics) []opSeries {
+		return []opSeries{{"", &m.scans}}
+	})
+	family("qwick_scan_entries_total", "Entries returned by scans.", func(m *Metrics) []opSeries {
+		return []opSeries{{"", &m.scanEntries}}
+	})
+	family("qwick_returned_bytes_total", "Value bytes returned to callers.", func(m *Metrics) []opSeries {
+		return []opSeries{{"", &m.bytes}}
+	})
+
+	single := func(name, help string, get func(m *Metrics) any) {
+		fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s counter\n", name, help, name)
+		for _, m := range ms {
+			fmt.Fprintf(w, "%s{db=%q} %v\n", name, m.name, get(m))
+		}
+	}
+	single("qwick_decodes_total", "Value and block decompressions.", func(m *Metrics) any { return m.decodes.Load() })
+	single("qwick_decode_errors_total", "Failed decompressions.", func(m *Metrics) any { return m.decodeErrors.Load() })
+	single("qwick_decode_seconds_total", "Time spent decompressing.", func(m *Metrics) any {
+		return time.Duration(m.decodeNanos.Load()).Seconds()
+	})
+	single("qwick_decoded_bytes_total", "Bytes produced by decompression.", func(m *Metrics) any { return m.decodeBytes.Load() })
+}
@@ -0,0 +1,79 @@
+package qwick
+
+import (
+	"expvar"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestObserverMetrics(t *testing.T) {
+	dir := t.TempDir()
+	m := NewMetrics("users")
+	for _, opts := range []BuildOptions{{Compression: compZstd}, {Layout: LayoutBlocks}} {
+		path := filepath.Join(dir, "obs.qwick")
+		if err := BuildWithOptions(jsonTree(50), path, opts); err != nil {
+			t.Fatal(err)
+		}
+		db, err := OpenWithOptions(path, OpenOptions{Observer: m})
+		if err != nil {
+			t.Fatal(err)
+		}
+		db.GetRaw([]byte("user:000001"))
+		db.GetRaw([]byte("missing"))
+		if _, _, err := db.Find([]byte("user:000002"), nil); err != nil {
+			t.Fatal(err)
+		}
+		n := 0
+		db.Prefix([]byte("user:00001"), nil, func(k, v []byte) bool { n++; return true })
+		db.RangeRaw(nil, nil, func(k, v []byte) bool { return false })
+		db.Close()
+
+		if n == 0 {
+			t.Fatal("Prefix не вернул записей")
+		}
+	}
+
+	s := m.Snapshot()
+	if s.Hits["get_raw"] != 2 || s.Misses["get_raw"] != 2 || s.Hits["find"] != 2 {
+		t.Errorf("поиски: %+v / %+v", s.Hits, s.Misses)
+	}
+	if s.Scans["prefix"] != 2 || s.ScanEntries["prefix"] == 0 || s.ScanEntries["range_raw"] != 2 {
+		t.Errorf("обходы: %+v / %+v", s.Scans, s.ScanEntries)
+	}
+	if s.Decodes == 0 || s.DecodeErrors != 0 || s.Bytes["find"] == 0 {
+		t.Errorf("распаковки: %+v", s)
+	}
+
+	rec := httptest.NewRecorder()
+	MetricsHandler(m).ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+	body := rec.Body.String()
+	for _, want := range []string{
+		"# TYPE qwick_lookups_total counter\n",
+		`qwick_lookups_total{db="users",op="get_raw",result="hit"} 2`,
+		`qwick_lookups_total{db="users",op="get_raw",result="miss"} 2`,
+		`qwick_scans_total{db="users",op="prefix"} 2`,
+		`qwick_decode_errors_total{db="users"} 0`,
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("вывод Prometheus не содержит %q:\n%s", want, body)
+		}
+	}
+
+	m.Publish("qwick_test_users")
+	if v := expvar.Get("qwick_test_users"); v == nil || !strings.Contains(v.String(), `"get_raw":2`) {
+		t.Errorf("expvar: %v", v)
+	}
+}
+
+func TestObserverNil(t *testing.T) {
+	db := buildTestDB(t, filepath.Join(t.TempDir(), "nil.qwick"), map[string]string{"a": "1"}, BuildOptions{})
+	allocs := testing.AllocsPerRun(100, func() {
+		db.GetRaw([]byte("a"))
+		db.PrefixRaw([]byte("a"), func(k, v []byte) bool { return true })
+	})
+	if allocs > 1 {
+		t.Errorf("без наблюдателя обход выделяет память: %v", allocs)
+	}
+}
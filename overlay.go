@@ -0,0 +1,192 @@
+package qwick
+
+import "errors"
+
+// tombstone - тип значения-надгробия.
+type tombstone struct{}
+
+// Tombstone - значение для вставки в дерево, помечающее ключ удалённым.
+// Файл с надгробиями становится дельта-файлом: сам он скрывает такие ключи,
+// а в Overlay они скрывают версии ключа из нижних слоёв.
+var Tombstone any = tombstone{}
+
+// addTombstone дописывает запись-надгробие с пустым значением.
+func (b *builder) addTombstone(key []byte) error {
+	b.tombs = append(b.tombs, uint64(len(b.indices)))
+	b.stats.Tombstones++
+	_, err := b.addStored(key, nil)
+	return err
+}
+
+// dropOrTombstone переносит удаление ключа в результат слияния или отбрасывает его.
+func (b *builder) dropOrTombstone(key []byte, drop bool) error {
+	if drop {
+		return nil
+	}
+	return b.addTombstone(key)
+}
+
+func encodeTombstones(tombs []uint64, num uint64) []byte {
+	bm := make([]byte, (num+7)/8)
+	for _, i := range tombs {
+		bm[i/8] |= 1 << (i % 8)
+	}
+	return bm
+}
+
+// deleted сообщает, что запись i - надгробие.
+func (db *MMAPDB) deleted(i uint64) bool {
+	return db.tombs != nil && db.tombs[i/8]&(1<<(i%8)) != 0
+}
+
+// Overlay - слоёное чтение: неизменяемая база и поверх неё дельта-файлы.
+// Для каждого ключа видна версия из самого нового слоя (у повторяющегося ключа -
+// весь набор значений этого слоя); надгробия и истёкшие записи скрывают ключ.
+type Overlay struct {
+	layers []*MMAPDB // от базы к самому новому слою
+}
+
+// NewOverlay создаёт слоёное чтение. Первый слой - база, последний - самая новая дельта.
+func NewOverlay(layers ...*MMAPDB) *Overlay {
+	return &Overlay{layers: layers}
+}
+
+// Close закрывает все слои.
+func (o *Overlay) Close() error {
+	var errs []error
+	for _, db := range o.layers {
+		errs = append(errs, db.Close())
+	}
+	return errors.Join(errs...)
+}
+
+// find возвращает самый новый слой, содержащий ключ, и номер первой видимой записи
+// в нём. Слой задаёт весь набор значений ключа: если все записи скрыты, ключа нет.
+func (o *Overlay) find(key []byte) (*MMAPDB, uint64, bool) {
+	for i := len(o.layers) - 1; i >= 0; i-- {
+		db := o.layers[i]
+		lo, hi := db.keyRange(key)
+		if lo == hi {
+			continue
+		}
+		for idx := lo; idx < hi; idx++ {
+			if !db.hidden(idx) {
+				return db, idx, true
+			}
+		}
+		return nil, 0, false
+	}
+	return nil, 0, false
+}
+
+// GetRaw возвращает сырое значение ключа из самого нового слоя (в кодеке этого слоя).
+func (o *Overlay) GetRaw(key []byte) ([]byte, bool) {
+	db, idx, ok := o.find(key)
+	if !ok {
+		return nil, false
+	}
+	v := db.getValSlice(idx)
+	return v, v != nil
+}
+
+// Find возвращает распакованное значение ключа из самого нового слоя.
+func (o *Overlay) Find(key []byte, dst []byte) ([]byte, bool, error) {
+	db, idx, ok := o.find(key)
+	if !ok {
+		return nil, false, nil
+	}
+	return db.valueAt(idx, dst)
+}
+
+// PrefixRaw перебирает видимые ключи всех слоёв, начинающиеся с prefix.
+func (o *Overlay) PrefixRaw(prefix []byte, cb func(key, val []byte) bool) {
+	o.scanRaw(prefix, hasPrefix(prefix), cb)
+}
+
+// Prefix похож на PrefixRaw, но распаковывает значения.
+func (o *Overlay) Prefix(prefix []byte, dst []byte, cb func(key, val []byte) bool) error {
+	return o.scanValues(prefix, hasPrefix(prefix), dst, cb)
+}
+
+// RangeRaw перебирает видимые ключи из [lo, hi). hi == nil - до конца.
+func (o *Overlay) RangeRaw(lo, hi []byte, cb func(key, val []byte) bool) {
+	o.scanRaw(lo, below(hi), cb)
+}
+
+// Range похож на RangeRaw, но распаковывает значения.
+func (o *Overlay) Range(lo, hi []byte, dst []byte, cb func(key, val []byte) bool) error {
+	return o.scanValues(lo, below(hi), dst, cb)
+}
+
+// scan обходит слои слиянием и передаёт fn видимые записи самой новой версии
+// каждого ключа (все значения повторяющегося ключа из одного слоя).
+func (o *Overlay) scan(lo []byte, inRange func(k []byte) bool, fn func(c *mergeCursor, i uint64) bool) {
+	it := newMergeIter(o.layers, lo)
+	for it.next() {
+		c := it.group[len(it.group)-1]
+		if !inRange(c.key) {
+			return
+		}
+		for i := c.pos; i < c.end; i++ {
+			if c.db.hidden(i) {
+				continue
+			}
+			if !fn(c, i) {
+				return
+			}
+		}
+	}
+}
+
+func (o *Overlay) scanRaw(lo []byte, inRange func(k []byte) bool, cb func(key, val []byte) bool) {
+	o.scan(lo, inRange, func(c *mergeCursor, i uint64) bool {
+		v := c.db.getValSlice(i)
+		if v == nil {
+			return false
+		}
+		return cb(c.key, v)
+	})
+}
+
+func (o *Overlay) scanValues(lo []byte, inRange func(k []byte) bool, dst []byte, cb func(key, val []byte) bool) error {
+	var err error
+	o.scan(lo, inRange, func(c *mergeCursor, i uint64) bool {
+		v, ok, e := c.db.valueAt(i, dst)
+		if !ok {
+			return false
+		}
+		if e != nil {
+			err = e
+			return false
+		}
+		return cb(c.key, v)
+	})
+	return err
+}
+
+// Compact сворачивает слои в новую базу dst: остаются самые новые версии ключей,
+// удалённые ключи отбрасываются. Параметры сборки и метаданные берутся у базового слоя;
+// для слоёв с вторичными индексами нужен CompactWithOptions.
+func Compact(dst string, o *Overlay) error {
+	if len(o.layers) == 0 {
+		return errors.New("нет слоёв для сжатия")
+	}
+	return CompactWithOptions(dst, o, o.layers[0].buildOptions())
+}
+
+// CompactWithOptions сворачивает слои в новую базу dst с заданными параметрами сборки.
+// Каждый вторичный индекс слоёв должен быть объявлен в opts.Indexes.
+func CompactWithOptions(dst string, o *Overlay, opts BuildOptions) error {
+	if len(o.layers) == 0 {
+		return errors.New("нет слоёв для сжатия")
+	}
+	if err := rejectSpaces(o.layers); err != nil {
+		return err
+	}
+	if err := checkIndexes(o.layers, opts); err != nil {
+		return err
+	}
+	return buildFile(dst, opts, nil, func(b *builder) error {
+		return mergeInto(b, o.layers, LastWins, true)
+	})
+}
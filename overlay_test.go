@@ -0,0 +1,149 @@
+package qwick
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func buildDelta(t *testing.T, path string, puts map[string]string, dels []string, opts BuildOptions) *MMAPDB {
+	t.Helper()
+	tree := New()
+	for k, v := range puts {
+		tree.Insert([]byte(k), []byte(v))
+	}
+	for _, k := range dels {
+		tree.Insert([]byte(k), Tombstone)
+	}
+	if err := BuildWithOptions(tree, path, opts); err != nil {
+		t.Fatalf("Ошибка BuildWithOptions: %v", err)
+	}
+	db, err := Open(path)
+	if err != nil {
+		t.Fatalf("Ошибка Open: %v", err)
+	}
+	return db
+}
+
+func TestOverlay(t *testing.T) {
+	tmpDir, _ := os.MkdirTemp("", "qwick_overlay")
+	defer os.RemoveAll(tmpDir)
+
+	base := buildDelta(t, filepath.Join(tmpDir, "base.qwick"),
+		map[string]string{"a": "1", "b": "2", "c": "3", "d": "4"}, nil, BuildOptions{Compression: compZstd})
+	d1 := buildDelta(t, filepath.Join(tmpDir, "d1.qwick"),
+		map[string]string{"b": "2.1", "e": "5"}, []string{"c"}, BuildOptions{Compression: compS2})
+	d2 := buildDelta(t, filepath.Join(tmpDir, "d2.qwick"),
+		map[string]string{"c": "3.2"}, []string{"a", "e"}, BuildOptions{Layout: LayoutBlocks})
+	ov := NewOverlay(base, d1, d2)
+	defer ov.Close()
+
+	// Дельта-файл сам по себе скрывает удалённые ключи.
+	if _, ok := d1.GetRaw([]byte("c")); ok {
+		t.Error("Удалённый ключ виден в дельта-файле")
+	}
+
+	want := map[string]string{"b": "2.1", "c": "3.2", "d": "4"}
+	for _, k := range []string{"a", "b", "c", "d", "e", "z"} {
+		val, ok, err := ov.Find([]byte(k), nil)
+		if err != nil {
+			t.Fatalf("Ошибка Find %s: %v", k, err)
+		}
+		if w, exists := want[k]; ok != exists || string(val) != w {
+			t.Errorf("Find %s: получено %q (%v), ожидалось %q (%v)", k, val, ok, w, exists)
+		}
+	}
+	if _, ok := ov.GetRaw([]byte("a")); ok {
+		t.Error("GetRaw: удалённый ключ виден")
+	}
+
+	got := make(map[string]string)
+	if err := ov.Prefix(nil, nil, func(k, v []byte) bool {
+		got[string(k)] = string(v)
+		return true
+	}); err != nil {
+		t.Fatalf("Ошибка Prefix: %v", err)
+	}
+	if fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("Prefix: получено %v, ожидалось %v", got, want)
+	}
+
+	var keys []string
+	ov.RangeRaw([]byte("b"), []byte("d"), func(k, v []byte) bool {
+		keys = append(keys, string(k))
+		return true
+	})
+	if fmt.Sprint(keys) != "[b c]" {
+		t.Errorf("RangeRaw: получено %v", keys)
+	}
+
+	dst := filepath.Join(tmpDir, "compacted.qwick")
+	if err := Compact(dst, ov); err != nil {
+		t.Fatalf("Ошибка Compact: %v", err)
+	}
+	db, err := Open(dst)
+	if err != nil {
+		t.Fatalf("Ошибка Open: %v", err)
+	}
+	defer db.Close()
+	if db.tombs != nil || db.num != 3 {
+		t.Errorf("Сжатая база должна содержать 3 записи без надгробий, получено %d", db.num)
+	}
+	if got := dumpDB(t, db); fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("Compact: получено %v, ожидалось %v", got, want)
+	}
+}
+
+func TestMergeKeepsTombstones(t *testing.T) {
+	tmpDir, _ := os.MkdirTemp("", "qwick_merge_tombs")
+	defer os.RemoveAll(tmpDir)
+
+	d1 := buildDelta(t, filepath.Join(tmpDir, "d1.qwick"), map[string]string{"a": "1"}, []string{"b"}, BuildOptions{})
+	d2 := buildDelta(t, filepath.Join(tmpDir, "d2.qwick"), map[string]string{"c": "3"}, []string{"a"}, BuildOptions{})
+	defer d1.Close()
+	defer d2.Close()
+
+	dst := filepath.Join(tmpDir, "merged.qwick")
+	if err := Merge(dst, []*MMAPDB{d1, d2}, LastWins); err != nil {
+		t.Fatalf("Ошибка Merge: %v", err)
+	}
+	db, err := Open(dst)
+	if err != nil {
+		t.Fatalf("Ошибка Open: %v", err)
+	}
+	defer db.Close()
+
+	if db.num != 3 {
+		t.Errorf("Ожидалось 3 записи (2 надгробия), получено %d", db.num)
+	}
+	if got := dumpDB(t, db); fmt.Sprint(got) != "map[c:3]" {
+		t.Errorf("Получено %v", got)
+	}
+}
+
+func TestRange(t *testing.T) {
+	tmpDir, _ := os.MkdirTemp("", "qwick_range")
+	defer os.RemoveAll(tmpDir)
+
+	db := buildTestDB(t, filepath.Join(tmpDir, "range.qwick"),
+		map[string]string{"a": "1", "b": "2", "c": "3", "d": "4"}, BuildOptions{Compression: compZstd})
+
+	var got []string
+	err := db.Range([]byte("b"), []byte("d"), nil, func(k, v []byte) bool {
+		got = append(got, string(k)+"="+string(v))
+		return true
+	})
+	if err != nil || fmt.Sprint(got) != "[b=2 c=3]" {
+		t.Errorf("Range: получено %v, err %v", got, err)
+	}
+
+	got = got[:0]
+	db.RangeRaw([]byte("bb"), nil, func(k, v []byte) bool {
+		got = append(got, string(k))
+		return true
+	})
+	if fmt.Sprint(got) != "[c d]" {
+		t.Errorf("RangeRaw: получено %v", got)
+	}
+}
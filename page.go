@@ -0,0 +1,148 @@
+package qwick
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+)
+
+// Entry - пара ключ-значение, скопированная из базы.
+type Entry struct {
+	Key   []byte
+	Value []byte
+}
+
+// ErrPageToken - токен страницы повреждён, выдан для другого префикса (диапазона)
+// или для другой версии файла (например, после горячей замены).
+var ErrPageToken = errors.New("недействительный токен страницы")
+
+// Токен страницы: версия(1) + номер записи(8) + отпечаток файла(8) + отпечаток префикса или диапазона(8).
+const (
+	pageTokenVersion = 1
+	pageTokenSize    = 1 + 8 + 8 + 8
+)
+
+// PrefixPage возвращает до limit записей с префиксом prefix (значения распакованы)
+// и токен следующей страницы. Пустой token - первая страница, пустой следующий
+// токен - записей больше нет. Токен хранит номер записи в индексе, поэтому
+// продолжение не требует поиска; если файл или префикс не совпадают с теми,
+// для которых выдан токен, возвращается ErrPageToken. Токен привязан к хэшу
+// содержимого, поэтому для файлов без него (старых версий) обход недоступен.
+func (db *MMAPDB) PrefixPage(prefix []byte, token string, limit int) ([]Entry, string, error) {
+	return db.page(prefix, hasPrefix(prefix), prefixFingerprint(prefix), token, limit)
+}
+
+// RangePage похож на PrefixPage, но перебирает ключи из полуинтервала [lo, hi)
+// (hi == nil - до конца базы). Токен привязан к границам диапазона.
+func (db *MMAPDB) RangePage(lo, hi []byte, token string, limit int) ([]Entry, string, error) {
+	return db.page(lo, below(hi), rangeFingerprint(lo, hi), token, limit)
+}
+
+// page возвращает страницу записей от первого ключа >= lo, пока inRange истинно.
+// scope - отпечаток запроса, к которому привязан токен.
+func (db *MMAPDB) page(lo []byte, inRange func(k []byte) bool, scope uint64, token string, limit int) ([]Entry, string, error) {
+	if limit <= 0 {
+		return nil, "", errors.New("limit должен быть положительным")
+	}
+	if _, ok := db.fingerprint(); !ok {
+		return nil, "", fmt.Errorf("%w: файл собран без хэша содержимого", ErrPageToken)
+	}
+	var pos uint64
+	if token == "" {
+		pos, _ = db.findIndex(lo)
+	} else {
+		var err error
+		if pos, err = db.parsePageToken(token, scope); err != nil {
+			return nil, "", err
+		}
+	}
+
+	var out []Entry
+	for ; pos < db.num && len(out) < limit; pos++ {
+		k := db.getKeySlice(pos)
+		if k == nil || !inRange(k) {
+			return out, "", nil
+		}
+		if db.hidden(pos) {
+			continue
+		}
+		v, err := db.value(pos)
+		if err != nil {
+			return nil, "", err
+		}
+		out = append(out, Entry{Key: bytes.Clone(k), Value: bytes.Clone(v)})
+	}
+	if pos >= db.num {
+		return out, "", nil
+	}
+	if k := db.getKeySlice(pos); k == nil || !inRange(k) {
+		return out, "", nil
+	}
+	return out, db.pageToken(pos, scope), nil
+}
+
+// fingerprint - отпечаток содержимого: начало хэша содержимого файла, а у
+// пространства имён - хэша общего файла с именем. false - хэша нет: заголовок
+// одинаковой раскладки совпадает у разных файлов и отпечатком служить не может.
+func (db *MMAPDB) fingerprint() (uint64, bool) {
+	h := db.spaceHash
+	if h == nil {
+		h = db.section(secHash)
+	}
+	if len(h) < 8 {
+		return 0, false
+	}
+	return binary.LittleEndian.Uint64(h), true
+}
+
+func prefixFingerprint(prefix []byte) uint64 {
+	sum := sha256.Sum256(prefix)
+	return binary.LittleEndian.Uint64(sum[:])
+}
+
+// rangeFingerprint отличает диапазоны друг от друга и от префиксов: длина lo
+// разделяет границы, а отдельный байт - пустую верхнюю границу от её отсутствия.
+func rangeFingerprint(lo, hi []byte) uint64 {
+	h := sha256.New()
+	h.Write([]byte("range"))
+	h.Write(binary.LittleEndian.AppendUint64(nil, uint64(len(lo))))
+	h.Write(lo)
+	if hi == nil {
+		h.Write([]byte{0})
+	} else {
+		h.Write([]byte{1})
+		h.Write(hi)
+	}
+	return binary.LittleEndian.Uint64(h.Sum(nil))
+}
+
+func (db *MMAPDB) pageToken(pos uint64, scope uint64) string {
+	b := make([]byte, pageTokenSize)
+	b[0] = pageTokenVersion
+	binary.LittleEndian.PutUint64(b[1:9], pos)
+	fp, _ := db.fingerprint()
+	binary.LittleEndian.PutUint64(b[9:17], fp)
+	binary.LittleEndian.PutUint64(b[17:25], scope)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+func (db *MMAPDB) parsePageToken(token string, scope uint64) (uint64, error) {
+	b, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil || len(b) != pageTokenSize || b[0] != pageTokenVersion {
+		return 0, ErrPageToken
+	}
+	if fp, _ := db.fingerprint(); binary.LittleEndian.Uint64(b[9:17]) != fp {
+		return 0, fmt.Errorf("%w: файл базы изменился", ErrPageToken)
+	}
+	if binary.LittleEndian.Uint64(b[17:25]) != scope {
+		return 0, fmt.Errorf("%w: токен выдан для другого префикса или диапазона", ErrPageToken)
+	}
+	pos := binary.LittleEndian.Uint64(b[1:9])
+	if pos > db.num {
+		return 0, ErrPageToken
+	}
+	return pos, nil
+}
@@ -0,0 +1,194 @@
+package qwick
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestPrefixPage(t *testing.T) {
+	dir := t.TempDir()
+	tree := New()
+	for i := range 100 {
+		tree.Insert([]byte(fmt.Sprintf("a/%03d", i)), []byte(fmt.Sprint(i)))
+		tree.Insert([]byte(fmt.Sprintf("b/%03d", i)), []byte(fmt.Sprint(i)))
+	}
+	tree.Insert([]byte("a/050"), Tombstone)
+	path := filepath.Join(dir, "page.qwick")
+	if err := BuildWithOptions(tree, path, BuildOptions{Compression: compS2}); err != nil {
+		t.Fatal(err)
+	}
+	db, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	var got []string
+	token, pages := "", 0
+	for {
+		entries, next, err := db.PrefixPage([]byte("a/"), token, 7)
+		if err != nil {
+			t.Fatalf("PrefixPage: %v", err)
+		}
+		pages++
+		for _, e := range entries {
+			got = append(got, string(e.Key)+"="+string(e.Value))
+		}
+		if next == "" {
+			break
+		}
+		token = next
+	}
+	if len(got) != 99 || got[0] != "a/000=0" || got[98] != "a/099=99" || pages != 15 {
+		t.Fatalf("страниц %d, записей %d: %v", pages, len(got), got)
+	}
+	for _, kv := range got {
+		if kv == "a/050=50" {
+			t.Error("удалённый ключ попал в страницу")
+		}
+	}
+
+	_, token, _ = db.PrefixPage([]byte("a/"), "", 10)
+	if _, _, err := db.PrefixPage([]byte("b/"), token, 10); !errors.Is(err, ErrPageToken) {
+		t.Errorf("чужой префикс: %v", err)
+	}
+	if _, _, err := db.PrefixPage([]byte("a/"), "garbage!", 10); !errors.Is(err, ErrPageToken) {
+		t.Errorf("повреждённый токен: %v", err)
+	}
+	if entries, next, err := db.PrefixPage([]byte("zzz"), "", 10); err != nil || len(entries) != 0 || next != "" {
+		t.Errorf("пустой префикс: %v %q %v", entries, next, err)
+	}
+
+	// После замены файла старый токен отклоняется.
+	tree.Insert([]byte("a/000"), []byte("new"))
+	swapped := filepath.Join(dir, "swapped.qwick")
+	if err := BuildWithOptions(tree, swapped, BuildOptions{Compression: compS2}); err != nil {
+		t.Fatal(err)
+	}
+	db2, err := Open(swapped)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db2.Close()
+	if _, _, err := db2.PrefixPage([]byte("a/"), token, 10); !errors.Is(err, ErrPageToken) {
+		t.Errorf("токен после замены файла: %v", err)
+	}
+}
+
+func TestRangePage(t *testing.T) {
+	kv := make(map[string]string)
+	for i := range 100 {
+		kv[fmt.Sprintf("k%03d", i)] = fmt.Sprint(i)
+	}
+	db := buildTestDB(t, filepath.Join(t.TempDir(), "range.qwick"), kv, BuildOptions{})
+
+	var got []string
+	token, pages := "", 0
+	for {
+		entries, next, err := db.RangePage([]byte("k010"), []byte("k030"), token, 6)
+		if err != nil {
+			t.Fatalf("RangePage: %v", err)
+		}
+		pages++
+		for _, e := range entries {
+			got = append(got, string(e.Key)+"="+string(e.Value))
+		}
+		if next == "" {
+			break
+		}
+		token = next
+	}
+	if len(got) != 20 || got[0] != "k010=10" || got[19] != "k029=29" || pages != 4 {
+		t.Fatalf("страниц %d, записей %d: %v", pages, len(got), got)
+	}
+
+	entries, token, err := db.RangePage([]byte("k090"), nil, "", 5)
+	if err != nil || len(entries) != 5 || token == "" {
+		t.Fatalf("открытый диапазон: %d %q %v", len(entries), token, err)
+	}
+	if entries, next, err := db.RangePage([]byte("k090"), nil, token, 5); err != nil || len(entries) != 5 || next != "" {
+		t.Errorf("конец открытого диапазона: %d %q %v", len(entries), next, err)
+	}
+
+	// Токен привязан к границам диапазона и не подходит префиксу.
+	for name, fn := range map[string]func() error{
+		"другая нижняя граница": func() error { _, _, err := db.RangePage([]byte("k091"), nil, token, 5); return err },
+		"другая верхняя граница": func() error {
+			_, _, err := db.RangePage([]byte("k090"), []byte("k099"), token, 5)
+			return err
+		},
+		"пустая верхняя граница": func() error { _, _, err := db.RangePage([]byte("k090"), []byte{}, token, 5); return err },
+		"префикс": func() error { _, _, err := db.PrefixPage([]byte("k090"), token, 5); return err },
+	} {
+		if err := fn(); !errors.Is(err, ErrPageToken) {
+			t.Errorf("%s: %v", name, err)
+		}
+	}
+}
+
+func TestPrefixPageNamespaces(t *testing.T) {
+	dir := t.TempDir()
+	// Файлы с одинаковой раскладкой: длины ключей и значений совпадают, заголовки тоже.
+	build := func(name, val string) *MMAPDB {
+		var kv []string
+		for i := range 10 {
+			kv = append(kv, fmt.Sprintf("k%d", i), val)
+		}
+		path := filepath.Join(dir, name)
+		specs := []NamespaceSpec{{Name: "u", Entries: pairs(kv...)}, {Name: "v", Entries: pairs(kv...)}}
+		if err := BuildNamespaces(path, specs, BuildOptions{}); err != nil {
+			t.Fatal(err)
+		}
+		db, err := Open(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { db.Close() })
+		return db
+	}
+	n1, n2 := build("n1.qwick", "one"), build("n2.qwick", "two")
+
+	_, token, err := n1.Namespace("u").PrefixPage([]byte("k"), "", 3)
+	if err != nil || token == "" {
+		t.Fatalf("PrefixPage: %q, %v", token, err)
+	}
+	if _, _, err := n1.Namespace("u").PrefixPage([]byte("k"), token, 3); err != nil {
+		t.Errorf("продолжение в том же пространстве: %v", err)
+	}
+	for name, db := range map[string]*MMAPDB{"другой файл": n2.Namespace("u"), "другое пространство": n1.Namespace("v")} {
+		if _, _, err := db.PrefixPage([]byte("k"), token, 3); !errors.Is(err, ErrPageToken) {
+			t.Errorf("%s: ожидалась ErrPageToken, получено %v", name, err)
+		}
+	}
+}
+
+func TestPrefixPageWithoutHash(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "old.qwick")
+	if err := Build(jsonTree(10), path); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Прячем хэш содержимого, как в файле старой версии.
+	db, err := OpenBytes(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, s := range db.sections {
+		if s.kind == secHash {
+			binary.LittleEndian.PutUint32(data[db.hdr.OffSections+uint64(i)*sectionEntrySize:], 0xff)
+		}
+	}
+	if db, err = OpenBytes(data); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := db.PrefixPage(nil, "", 3); !errors.Is(err, ErrPageToken) {
+		t.Errorf("ожидалась ErrPageToken для файла без хэша, получено %v", err)
+	}
+}
@@ -0,0 +1,167 @@
+package qwick
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+)
+
+// Advice - подсказка ядру о характере доступа к отображённому файлу (madvise).
+type Advice int
+
+const (
+	AdviceNormal     Advice = iota // поведение по умолчанию
+	AdviceRandom                   // MADV_RANDOM: без упреждающего чтения, для точечных поисков
+	AdviceSequential               // MADV_SEQUENTIAL: агрессивное упреждающее чтение
+	AdviceWillNeed                 // MADV_WILLNEED: начать подгрузку файла сразу
+)
+
+var pageSize = uint64(os.Getpagesize())
+
+// applyPaging применяет настройки страничного кэша из OpenOptions.
+func (db *MMAPDB) applyPaging(opts OpenOptions) error {
+	if !db.mapped {
+		return nil
+	}
+	db.advice = opts.Advice
+	if opts.SequentialScan > 0 {
+		db.seqScan = uint64(opts.SequentialScan)
+	}
+	if opts.Advice != AdviceNormal {
+		if err := madvise(db.mdata, opts.Advice); err != nil {
+			return fmt.Errorf("ошибка madvise: %w", err)
+		}
+	}
+	if opts.LockIndex {
+		for _, r := range db.indexRegions() {
+			if err := mlock(db.pages(r.off, r.size)); err != nil {
+				return fmt.Errorf("ошибка mlock индекса: %w", err)
+			}
+		}
+	}
+	for _, ns := range db.spaces {
+		ns.mapped, ns.advice, ns.seqScan = true, db.advice, db.seqScan
+	}
+	return nil
+}
+
+// indexRegions возвращает области, нужные для поиска: индекс и секции (таблица
+// блоков, вторичные индексы, карты удалений и сроков), включая пространства имён.
+func (db *MMAPDB) indexRegions() []section {
+	regions := []section{{off: db.indexBase, size: db.num * db.indexSize}}
+	if db.hdr.OffSections != 0 {
+		regions = append(regions, section{off: db.hdr.OffSections, size: uint64(db.hdr.NumSections) * sectionEntrySize})
+	}
+	regions = append(regions, db.sections...)
+	for _, name := range db.Namespaces() {
+		regions = append(regions, db.spaces[name].indexRegions()...)
+	}
+	return regions
+}
+
+// pages возвращает участок отображения [off, off+n), расширенный до границ страниц.
+func (db *MMAPDB) pages(off, n uint64) []byte {
+	start := off &^ (pageSize - 1)
+	end := min(off+n, db.size)
+	if start >= end {
+		return nil
+	}
+	return db.mdata[start:end]
+}
+
+// madvise применяет подсказку к участку отображения. Тесты подменяют её, чтобы
+// проверить последовательность подсказок.
+var madvise = sysMadvise
+
+// scanAdvice отслеживает одновременные долгие обходы: подсказка снимается, когда
+// завершается последний из них.
+type scanAdvice struct {
+	mu     sync.Mutex
+	active int
+	from   uint64 // наименьшая запись, с которой начат активный обход
+}
+
+// adviseScan помечает остаток файла от записи i последовательным на время долгого
+// обхода и возвращает функцию, снимающую подсказку после последнего из обходов.
+// Снятие возвращает MADV_RANDOM, если он был запрошен, иначе MADV_NORMAL:
+// WILLNEED повторно не применяется, чтобы не подгружать файл заново после каждого обхода.
+func (db *MMAPDB) adviseScan(i uint64) func() {
+	if !db.mapped {
+		return func() {}
+	}
+	data, index, ok := db.scanRegions(i)
+	if !ok {
+		return func() {}
+	}
+	db.scans.mu.Lock()
+	if db.scans.active == 0 || i < db.scans.from {
+		db.scans.from = i
+	}
+	db.scans.active++
+	db.scans.mu.Unlock()
+	_ = madvise(data, AdviceSequential)
+	_ = madvise(index, AdviceSequential)
+
+	return func() {
+		db.scans.mu.Lock()
+		defer db.scans.mu.Unlock()
+		if db.scans.active--; db.scans.active > 0 {
+			return
+		}
+		restore := AdviceNormal
+		if db.advice == AdviceRandom {
+			restore = AdviceRandom
+		}
+		if data, index, ok := db.scanRegions(db.scans.from); ok {
+			_ = madvise(data, restore)
+			_ = madvise(index, restore)
+		}
+	}
+}
+
+// scanRegions возвращает участки данных и индекса от записи i до конца.
+func (db *MMAPDB) scanRegions(i uint64) (data, index []byte, ok bool) {
+	e, ok := db.readIndex(i)
+	if !ok {
+		return nil, nil, false
+	}
+	data = db.pages(e.koff, db.hdr.OffIndex-min(e.koff, db.hdr.OffIndex))
+	index = db.pages(db.indexBase+i*db.indexSize, (db.num-i)*db.indexSize)
+	return data, index, true
+}
+
+// Warmup заранее подгружает в память индекс и секции, чтобы первые поиски после
+// открытия (например, после горячей замены файла) не ждали чтения с диска.
+// Прерывается при отмене ctx и возвращает ctx.Err().
+func (db *MMAPDB) Warmup(ctx context.Context) error {
+	regions := db.indexRegions()
+	if db.mapped {
+		for _, r := range regions {
+			_ = madvise(db.pages(r.off, r.size), AdviceWillNeed)
+		}
+	}
+	var sink byte
+	for _, r := range regions {
+		for off := r.off; off < r.off+r.size; {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			n := min(uint64(chunkSize), r.off+r.size-off)
+			b := db.at(off, n)
+			if b == nil {
+				return errors.New("область индекса выходит за границы файла")
+			}
+			for j := 0; j < len(b); j += int(pageSize) {
+				sink ^= b[j]
+			}
+			off += n
+		}
+	}
+	warmSink = sink
+	return nil
+}
+
+// warmSink не даёт компилятору выбросить чтение страниц в Warmup.
+var warmSink byte
@@ -0,0 +1,26 @@
+//go:build linux
+
+package qwick
+
+import "golang.org/x/sys/unix"
+
+var sysAdvice = [...]int{
+	AdviceNormal:     unix.MADV_NORMAL,
+	AdviceRandom:     unix.MADV_RANDOM,
+	AdviceSequential: unix.MADV_SEQUENTIAL,
+	AdviceWillNeed:   unix.MADV_WILLNEED,
+}
+
+func sysMadvise(b []byte, advice Advice) error {
+	if len(b) == 0 || advice < 0 || int(advice) >= len(sysAdvice) {
+		return nil
+	}
+	return unix.Madvise(b, sysAdvice[advice])
+}
+
+func mlock(b []byte) error {
+	if len(b) == 0 {
+		return nil
+	}
+	return unix.Mlock(b)
+}
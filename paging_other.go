@@ -0,0 +1,9 @@
+//go:build !linux
+
+package qwick
+
+// На остальных платформах подсказки страничного кэша не применяются.
+
+func sysMadvise(b []byte, advice Advice) error { return nil }
+
+func mlock(b []byte) error { return nil }
@@ -0,0 +1,103 @@
+package qwick
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"syscall"
+	"testing"
+)
+
+func TestPagingOptions(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "paging.qwick")
+	if err := BuildWithOptions(jsonTree(2000), path, BuildOptions{Compression: compS2}); err != nil {
+		t.Fatal(err)
+	}
+
+	// Записываем применённые подсказки, чтобы проверить их порядок.
+	var applied []Advice
+	madvise = func(b []byte, advice Advice) error {
+		applied = append(applied, advice)
+		return sysMadvise(b, advice)
+	}
+	defer func() { madvise = sysMadvise }()
+
+	const (
+		normal = AdviceNormal
+		random = AdviceRandom
+		seq    = AdviceSequential
+	)
+	for _, tc := range []struct {
+		opts OpenOptions
+		scan []Advice // подсказки вложенных долгих обходов
+	}{
+		{opts: OpenOptions{Advice: AdviceRandom}},
+		{opts: OpenOptions{Advice: AdviceSequential}},
+		{opts: OpenOptions{Advice: AdviceWillNeed}},
+		{OpenOptions{Advice: AdviceRandom, SequentialScan: 10}, []Advice{seq, seq, seq, seq, random, random}},
+		{OpenOptions{Advice: AdviceWillNeed, SequentialScan: 10}, []Advice{seq, seq, seq, seq, normal, normal}},
+		{opts: OpenOptions{LockIndex: true}},
+	} {
+		opts := tc.opts
+		applied = nil
+		db, err := OpenWithOptions(path, opts)
+		if errors.Is(err, syscall.EPERM) || errors.Is(err, syscall.ENOMEM) {
+			// mlock ограничен RLIMIT_MEMLOCK или правами процесса.
+			t.Logf("%+v: %v", opts, err)
+			continue
+		}
+		if err != nil {
+			t.Fatalf("%+v: %v", opts, err)
+		}
+		var want []Advice
+		if opts.Advice != AdviceNormal {
+			want = []Advice{opts.Advice}
+		}
+		if fmt.Sprint(applied) != fmt.Sprint(want) {
+			t.Errorf("%+v: при открытии применено %v", opts, applied)
+		}
+		if err := db.Warmup(context.Background()); err != nil {
+			t.Errorf("Warmup: %v", err)
+		}
+
+		// Вложенный обход не снимает подсказку внешнего.
+		applied = nil
+		n := 0
+		db.PrefixRaw([]byte("user:000"), func(k, v []byte) bool {
+			if n++; n == 500 {
+				db.PrefixRaw([]byte("user:001"), func(k, v []byte) bool { return true })
+			}
+			return true
+		})
+		if n != 1000 {
+			t.Errorf("%+v: PrefixRaw вернул %d записей", opts, n)
+		}
+		if fmt.Sprint(applied) != fmt.Sprint(tc.scan) {
+			t.Errorf("%+v: при обходе применено %v, ожидалось %v", opts, applied, tc.scan)
+		}
+		v, ok, err := db.Find([]byte(fmt.Sprintf("user:%06d", 1999)), nil)
+		if err != nil || !ok || len(v) == 0 {
+			t.Errorf("%+v: Find: %v %v", opts, ok, err)
+		}
+		db.Close()
+	}
+}
+
+func TestWarmupCancel(t *testing.T) {
+	db := buildTestDB(t, filepath.Join(t.TempDir(), "w.qwick"), map[string]string{"a": "1"}, BuildOptions{})
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := db.Warmup(ctx); !errors.Is(err, context.Canceled) {
+		t.Errorf("Warmup с отменённым контекстом: %v", err)
+	}
+
+	// Для источников без mmap Warmup просто читает индекс.
+	src, err := OpenBytes(db.mdata)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := src.Warmup(context.Background()); err != nil {
+		t.Errorf("Warmup OpenBytes: %v", err)
+	}
+}
@@ -0,0 +1,395 @@
+package qwick
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// Формат патча: заголовок patchHeaderSize байт и сжатый zstd поток операций.
+// Заголовок: Magic(8) + Version(4) + резерв(4) + SHA-256 старого файла(32) +
+// SHA-256 нового файла(32) + размер нового файла(8).
+const (
+	PatchMagic      = "QWICKPT\x01"
+	patchVersion    = 1
+	patchHeaderSize = 8 + 4 + 4 + 32 + 32 + 8
+)
+
+// Операции патча. Каждая описывает очередной участок нового файла.
+const (
+	opEnd   = 0 // конец потока
+	opRaw   = 1 // len, bytes - байты как есть
+	opEntry = 2 // klen, key, vlen, value - новая запись (значение в хранимом виде)
+	opCopy  = 3 // first, count - записи старого файла, скопированные без изменений
+	opIndex = 4 // индекс, построенный по записям из opEntry и opCopy
+)
+
+// maxPatchField - предел длины одного поля патча (длины в индексе 32-битные).
+const maxPatchField = 1 << 32
+
+// MakePatch записывает в outPath патч, превращающий файл oldPath в newPath.
+// Неизменённые записи ссылаются на старый файл, новые и изменённые передаются
+// целиком, индекс восстанавливается по записям. Поток операций сжимается zstd;
+// для передачи по открытым каналам патч можно зашифровать ZipEncrypt.
+func MakePatch(oldPath, newPath, outPath string) error {
+	oldHash, err := fileHash(oldPath)
+	if err != nil {
+		return err
+	}
+	newHash, err := fileHash(newPath)
+	if err != nil {
+		return err
+	}
+	oldDB, err := Open(oldPath)
+	if err != nil {
+		return err
+	}
+	defer oldDB.Close()
+	newDB, err := Open(newPath)
+	if err != nil {
+		return err
+	}
+	defer newDB.Close()
+
+	return writeAtomic(outPath, func(w io.Writer) error {
+		hdr := make([]byte, patchHeaderSize)
+		copy(hdr[0:8], PatchMagic)
+		binary.LittleEndian.PutUint32(hdr[8:12], patchVersion)
+		copy(hdr[16:48], oldHash)
+		copy(hdr[48:80], newHash)
+		binary.LittleEndian.PutUint64(hdr[80:88], newDB.size)
+		if _, err := w.Write(hdr); err != nil {
+			return err
+		}
+
+		zw, err := zstd.NewWriter(w, zstd.WithEncoderLevel(zstd.SpeedDefault))
+		if err != nil {
+			return err
+		}
+		pw := &patchWriter{w: bufio.NewWriter(zw)}
+		if err := pw.ops(oldDB, newDB); err != nil {
+			zw.Close()
+			return err
+		}
+		if err := pw.w.Flush(); err != nil {
+			zw.Close()
+			return err
+		}
+		return zw.Close()
+	})
+}
+
+// patchWriter кодирует операции патча.
+type patchWriter struct {
+	w   *bufio.Writer
+	buf [binary.MaxVarintLen64]byte
+}
+
+func (pw *patchWriter) uvarint(v uint64) {
+	n := binary.PutUvarint(pw.buf[:], v)
+	pw.w.Write(pw.buf[:n])
+}
+
+func (pw *patchWriter) raw(p []byte) {
+	for len(p) > 0 {
+		n := min(len(p), maxPatchField-1)
+		pw.w.WriteByte(opRaw)
+		pw.uvarint(uint64(n))
+		pw.w.Write(p[:n])
+		p = p[n:]
+	}
+}
+
+// ops описывает новый файл участками: промежутки как есть, записи - ссылками на
+// старый файл или целиком. Записи участвуют, только если ключ и значение лежат
+// подряд (обычная раскладка, обычный индекс); остальные файлы передаются байтами.
+func (pw *patchWriter) ops(old, cur *MMAPDB) error {
+	var (
+		pos      uint64 // описанная часть нового файла
+		j        uint64 // курсор старого индекса
+		entries  uint64 // записи, описанные операциями записей
+		runFirst uint64
+		runCount uint64
+	)
+	flushRun := func() {
+		if runCount > 0 {
+			pw.w.WriteByte(opCopy)
+			pw.uvarint(runFirst)
+			pw.uvarint(runCount)
+			runCount = 0
+		}
+	}
+
+	entryOps := cur.blocks == nil && old.blocks == nil && cur.indexSize == indexEntrySize && old.indexSize == indexEntrySize
+	for i := uint64(0); entryOps && i < cur.num; i++ {
+		e, ok := cur.readIndex(i)
+		if !ok || e.voff != e.koff+uint64(e.klen) || e.koff < pos || e.voff+e.vlen > cur.hdr.OffIndex {
+			continue
+		}
+		rec := cur.at(e.koff, uint64(e.klen)+e.vlen)
+		if rec == nil {
+			continue
+		}
+		key := rec[:e.klen]
+
+		if e.koff > pos {
+			flushRun()
+			pw.raw(cur.at(pos, e.koff-pos))
+		}
+		pos = e.voff + e.vlen
+		entries++
+
+		for j < old.num {
+			if k := old.getKeySlice(j); k == nil || bytes.Compare(k, key) >= 0 {
+				break
+			}
+			j++
+		}
+		if j < old.num {
+			if oe, ok := old.readIndex(j); ok && oe.voff == oe.koff+uint64(oe.klen) &&
+				bytes.Equal(old.at(oe.koff, uint64(oe.klen)+oe.vlen), rec) {
+				if runCount == 0 || runFirst+runCount != j {
+					flushRun()
+					runFirst = j
+				}
+				runCount++
+				j++
+				continue
+			}
+		}
+		flushRun()
+		pw.w.WriteByte(opEntry)
+		pw.uvarint(uint64(e.klen))
+		pw.w.Write(key)
+		pw.uvarint(e.vlen)
+		pw.w.Write(rec[e.klen:])
+	}
+	flushRun()
+
+	// Индекс совпадает с восстановленным, только если все записи описаны операциями:
+	// их смещения в новом файле тогда те же, что и при применении патча.
+	indexEnd := cur.hdr.OffIndex + cur.num*indexEntrySize
+	if entries == cur.num && pos <= cur.hdr.OffIndex {
+		pw.raw(cur.at(pos, cur.hdr.OffIndex-pos))
+		pw.w.WriteByte(opIndex)
+		pos = indexEnd
+	}
+	pw.raw(cur.at(pos, cur.size-pos))
+	return pw.w.WriteByte(opEnd)
+}
+
+// ApplyPatch применяет патч patchPath к файлу oldPath и записывает результат в outPath.
+// Патч проверяет хэш старого файла, а результат - хэш нового: при несовпадении
+// возвращается ошибка, а outPath не создаётся.
+func ApplyPatch(oldPath, patchPath, outPath string) error {
+	pf, err := os.Open(patchPath)
+	if err != nil {
+		return err
+	}
+	defer pf.Close()
+
+	hdr := make([]byte, patchHeaderSize)
+	if _, err := io.ReadFull(pf, hdr); err != nil {
+		return fmt.Errorf("ошибка чтения заголовка патча: %w", err)
+	}
+	if string(hdr[0:8]) != PatchMagic {
+		return errors.New("неверная сигнатура патча")
+	}
+	if v := binary.LittleEndian.Uint32(hdr[8:12]); v != patchVersion {
+		return fmt.Errorf("неподдерживаемая версия патча: %d", v)
+	}
+	oldHash, err := fileHash(oldPath)
+	if err != nil {
+		return err
+	}
+	if !bytes.Equal(oldHash, hdr[16:48]) {
+		return errors.New("патч создан для другой версии файла")
+	}
+	newHash := hdr[48:80]
+	newSize := binary.LittleEndian.Uint64(hdr[80:88])
+
+	old, err := Open(oldPath)
+	if err != nil {
+		return err
+	}
+	defer old.Close()
+
+	zr, err := zstd.NewReader(pf)
+	if err != nil {
+		return err
+	}
+	defer zr.Close()
+
+	return writeAtomic(outPath, func(w io.Writer) error {
+		h := sha256.New()
+		pa := &patchApplier{r: bufio.NewReader(zr), w: bufio.NewWriter(io.MultiWriter(w, h)), old: old}
+		if err := pa.run(); err != nil {
+			return err
+		}
+		if err := pa.w.Flush(); err != nil {
+			return err
+		}
+		if pa.off != newSize || !bytes.Equal(h.Sum(nil), newHash) {
+			return errors.New("результат применения патча не совпадает с новым файлом")
+		}
+		return nil
+	})
+}
+
+// patchApplier воспроизводит новый файл по операциям патча.
+type patchApplier struct {
+	r       *bufio.Reader
+	w       *bufio.Writer
+	off     uint64
+	old     *MMAPDB
+	indices []indexEntry
+}
+
+func (pa *patchApplier) write(p []byte) error {
+	n, err := pa.w.Write(p)
+	pa.off += uint64(n)
+	return err
+}
+
+// field читает из потока поле с длиной-префиксом.
+func (pa *patchApplier) field() ([]byte, error) {
+	n, err := binary.ReadUvarint(pa.r)
+	if err != nil {
+		return nil, err
+	}
+	if n >= maxPatchField {
+		return nil, errors.New("некорректная длина в патче")
+	}
+	p := make([]byte, n)
+	_, err = io.ReadFull(pa.r, p)
+	return p, err
+}
+
+func (pa *patchApplier) run() error {
+	for {
+		op, err := pa.r.ReadByte()
+		if err != nil {
+			return fmt.Errorf("ошибка чтения патча: %w", err)
+		}
+		switch op {
+		case opEnd:
+			return nil
+		case opRaw:
+			p, err := pa.field()
+			if err != nil {
+				return err
+			}
+			if err := pa.write(p); err != nil {
+				return err
+			}
+		case opEntry:
+			key, err := pa.field()
+			if err != nil {
+				return err
+			}
+			val, err := pa.field()
+			if err != nil {
+				return err
+			}
+			if err := pa.entry(key, val); err != nil {
+				return err
+			}
+		case opCopy:
+			first, err := binary.ReadUvarint(pa.r)
+			if err != nil {
+				return err
+			}
+			count, err := binary.ReadUvarint(pa.r)
+			if err != nil {
+				return err
+			}
+			if first > pa.old.num || count > pa.old.num-first {
+				return errors.New("ссылка патча вне старого файла")
+			}
+			for j := first; j < first+count; j++ {
+				e, ok := pa.old.readIndex(j)
+				if !ok || e.voff != e.koff+uint64(e.klen) {
+					return errors.New("ошибка чтения индекса старого файла")
+				}
+				rec := pa.old.at(e.koff, uint64(e.klen)+e.vlen)
+				if rec == nil {
+					return errCorruptValue
+				}
+				if err := pa.entry(rec[:e.klen], rec[e.klen:]); err != nil {
+					return err
+				}
+			}
+		case opIndex:
+			if err := writeIndex(pa.write, pa.indices, false); err != nil {
+				return err
+			}
+		default:
+			return fmt.Errorf("неизвестная операция патча: %d", op)
+		}
+	}
+}
+
+// entry дописывает запись и запоминает её положение для индекса.
+func (pa *patchApplier) entry(key, val []byte) error {
+	koff := pa.off
+	if err := pa.write(key); err != nil {
+		return err
+	}
+	voff := pa.off
+	if err := pa.write(val); err != nil {
+		return err
+	}
+	pa.indices = append(pa.indices, indexEntry{koff, uint32(len(key)), voff, uint64(len(val)), false})
+	return nil
+}
+
+// fileHash возвращает SHA-256 содержимого файла.
+func fileHash(path string) ([]byte, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return nil, err
+	}
+	return h.Sum(nil), nil
+}
+
+// writeAtomic записывает файл path через временный файл; при ошибке path не меняется.
+func writeAtomic(path string, fn func(w io.Writer) error) (err error) {
+	tmp := path + ".tmp"
+	f, err := os.Create(tmp)
+	if err != nil {
+		return fmt.Errorf("ошибка создания временного файла: %w", err)
+	}
+	defer func() {
+		f.Close()
+		if err != nil {
+			os.Remove(tmp)
+		}
+	}()
+
+	bw := bufio.NewWriterSize(f, 1<<20)
+	if err := fn(bw); err != nil {
+		return err
+	}
+	if err := bw.Flush(); err != nil {
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
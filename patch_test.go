@@ -0,0 +1,105 @@
+package qwick
+
+import (
+	"bytes"
+	"crypto/rand"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestPatch(t *testing.T) {
+	tmpDir, _ := os.MkdirTemp("", "qwick_patch")
+	defer os.RemoveAll(tmpDir)
+
+	for _, tc := range []struct {
+		name string
+		opts BuildOptions
+	}{
+		{"auto", BuildOptions{ZstdLevel: 1, SizeCutover: 256}},
+		{"s2", BuildOptions{Compression: compS2}},
+		{"blocks", BuildOptions{Layout: LayoutBlocks, BlockSize: 1024}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			oldPath := filepath.Join(tmpDir, tc.name+"-old.qwick")
+			newPath := filepath.Join(tmpDir, tc.name+"-new.qwick")
+			patchPath := filepath.Join(tmpDir, tc.name+".patch")
+			outPath := filepath.Join(tmpDir, tc.name+"-out.qwick")
+
+			tree := jsonTree(2000)
+			if err := BuildWithOptions(tree, oldPath, tc.opts); err != nil {
+				t.Fatalf("Ошибка BuildWithOptions: %v", err)
+			}
+			tree.Delete([]byte("user:000010"))
+			tree.Insert([]byte("user:000500"), []byte("changed"))
+			tree.Insert([]byte("user:999999"), []byte("added"))
+			if err := BuildWithOptions(tree, newPath, tc.opts); err != nil {
+				t.Fatalf("Ошибка BuildWithOptions: %v", err)
+			}
+
+			if err := MakePatch(oldPath, newPath, patchPath); err != nil {
+				t.Fatalf("Ошибка MakePatch: %v", err)
+			}
+			if err := ApplyPatch(oldPath, patchPath, outPath); err != nil {
+				t.Fatalf("Ошибка ApplyPatch: %v", err)
+			}
+			want, _ := os.ReadFile(newPath)
+			got, _ := os.ReadFile(outPath)
+			if !bytes.Equal(got, want) {
+				t.Fatal("Результат патча отличается от нового файла")
+			}
+
+			pi, _ := os.Stat(patchPath)
+			if tc.opts.Layout == LayoutValues && pi.Size()*10 > int64(len(want)) {
+				t.Errorf("Патч слишком большой: %d байт при файле %d", pi.Size(), len(want))
+			}
+		})
+	}
+}
+
+func TestPatchWrongBase(t *testing.T) {
+	tmpDir, _ := os.MkdirTemp("", "qwick_patch_base")
+	defer os.RemoveAll(tmpDir)
+
+	paths := make([]string, 3)
+	for i := range paths {
+		paths[i] = filepath.Join(tmpDir, fmt.Sprintf("%d.qwick", i))
+		tree := New()
+		tree.Insert([]byte("k"), []byte(fmt.Sprint(i)))
+		if err := Build(tree, paths[i]); err != nil {
+			t.Fatalf("Ошибка Build: %v", err)
+		}
+	}
+	patchPath := filepath.Join(tmpDir, "p.patch")
+	if err := MakePatch(paths[0], paths[1], patchPath); err != nil {
+		t.Fatalf("Ошибка MakePatch: %v", err)
+	}
+	out := filepath.Join(tmpDir, "out.qwick")
+	if err := ApplyPatch(paths[2], patchPath, out); err == nil {
+		t.Error("Ожидалась ошибка применения к другому файлу")
+	}
+	if _, err := os.Stat(out); !os.IsNotExist(err) {
+		t.Error("Результат не должен создаваться при ошибке")
+	}
+
+	// Патч можно передавать зашифрованным.
+	key := make([]byte, 32)
+	rand.Read(key)
+	enc := filepath.Join(tmpDir, "p.patch.enc")
+	dec := filepath.Join(tmpDir, "p.patch.dec")
+	if err := ZipEncrypt(enc, patchPath, key); err != nil {
+		t.Fatalf("Ошибка ZipEncrypt: %v", err)
+	}
+	if err := UnzipDecrypt(dec, enc, key); err != nil {
+		t.Fatalf("Ошибка UnzipDecrypt: %v", err)
+	}
+	if err := ApplyPatch(paths[0], dec, out); err != nil {
+		t.Fatalf("Ошибка ApplyPatch: %v", err)
+	}
+	want, _ := os.ReadFile(paths[1])
+	got, _ := os.ReadFile(out)
+	if !bytes.Equal(got, want) {
+		t.Error("Результат патча отличается от нового файла")
+	}
+}
@@ -12,8 +12,9 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"math"
 	"os"
-	"path/filepath"
+	"time"
 
 	"github.com/edsrzf/mmap-go"
 	"github.com/klauspost/compress/s2"
@@ -26,7 +27,7 @@ import (
 // Константы формата файла QWICK
 const (
 	FileMagic   = "QWICK\xAB\xCD\xEF"
-	FileVersion = 1
+	FileVersion = 3 // старшая поддерживаемая версия; 3 - широкий индекс (flagWide)
 	headerSize  = 64
 	chunkSize   = 1 << 20 // 1MB
 )
@@ -41,30 +42,112 @@ const (
 // indexEntrySize - размер одной записи индекса (24 байта).
 const indexEntrySize = uint64(8 + 4 + 8 + 4)
 
+// wideEntrySize - размер записи широкого индекса (flagWide): Koff(8) + Klen(4) +
+// EntryFlags(4) + Voff(8) + Vlen(8). Используется, если хотя бы одно значение
+// не помещается в 32-битную длину.
+const wideEntrySize = uint64(8 + 4 + 4 + 8 + 8)
+
+// entryRaw - флаг записи широкого индекса: значение хранится без сжатия.
+const entryRaw = 1 << 0
+
+// narrowLimit - предел длины значения в обычном индексе. Значения длиннее хранятся
+// несжатыми в широком индексе. Переменная, чтобы тесты могли понизить порог.
+var narrowLimit uint64 = math.MaxUint32
+
+// indexEntry - разобранная запись индекса.
+type indexEntry struct {
+	koff uint64
+	klen uint32
+	voff uint64
+	vlen uint64
+	raw  bool // значение хранится без сжатия (только в широком индексе)
+}
+
+// Типы секций (версия формата 2+). Таблица секций лежит по смещению OffSections
+// и состоит из NumSections записей по sectionEntrySize байт: Kind, резерв, Off, Len.
+const (
+	secZstdDict = 1 // обученный словарь zstd
+	secBlocks   = 2 // таблица блоков (блочная раскладка)
+	secTombs    = 3 // битовая карта удалённых записей (дельта-файл)
+	secHash     = 4 // SHA-256 содержимого файла от конца заголовка до этой секции
+	secMeta     = 5 // пользовательские метаданные (BuildOptions.Metadata)
+	secIndex    = 6 // вторичный индекс (по секции на индекс)
+	secSpaces   = 7 // таблица пространств имён
+	secExpiry   = 8 // сроки действия записей: по uint64 (UnixNano, 0 - бессрочно) на запись
+	secStats    = 9 // статистика сборки (счётчики Stats)
+)
+
+// Флаги заголовка (поле Flags).
+const (
+	flagBlocks       = 1 << 0 // значения сгруппированы в сжатые блоки
+	flagUncompressed = 1 << 1 // ни одно значение не сжато
+	flagFooter       = 1 << 2 // настоящий заголовок записан в конце файла
+	flagDelta        = 1 << 3 // дельта-файл: есть записи-надгробия (tombstone)
+	flagDups         = 1 << 4 // есть повторяющиеся ключи (соседние записи)
+	flagSortedValues = 1 << 5 // значения повторяющихся ключей отсортированы
+	flagWide         = 1 << 6 // широкий индекс: 64-битные длины значений (версия 3)
+)
+
+const sectionEntrySize = uint64(4 + 4 + 8 + 8)
+
+// section описывает одну секцию файла.
+type section struct {
+	kind uint32
+	off  uint64
+	size uint64
+}
+
 // fileHeader представляет заголовок файла на диске.
 type fileHeader struct {
 	Magic       [8]byte
 	Version     uint32
-	_           uint32 // padding
+	Flags       uint32 // до версии 2 - padding
 	NumEntries  uint64
 	OffIndex    uint64
 	OffBlobs    uint64
 	ValueFmt    uint32 // 100 = generic
 	Compression uint32 // 0 = none, 1 = zstd, 2 = s2
+	OffSections uint64 // 0 = секций нет
+	NumSections uint32
+	_           uint32 // резерв
 }
 
 // MMAPDB представляет собой базу данных с доступом через memory-mapped file (только для чтения).
 type MMAPDB struct {
-	mdata       mmap.MMap
+	mdata       []byte      // содержимое файла (mmap или срез в памяти), nil для io.ReaderAt
+	size        uint64      // размер файла
+	ra          *pageReader // источник io.ReaderAt, nil для mmap и []byte
+	closer      func() error
 	hdr         fileHeader
 	indexBase   uint64
 	indexSize   uint64
 	num         uint64
 	compression uint32
+	sections    []section
+	zdec        *zstd.Decoder // декодер со словарём файла, nil - используется zstdDec
+	blocks      []byte        // таблица блоков, nil - обычная раскладка
+	bcache      blockCache
+	vcache      *valueCache // кэш распакованных значений, nil - выключен
+	tombs       []byte      // битовая карта удалённых записей, nil - удалений нет
+	expires     []byte      // сроки действия записей, nil - все записи бессрочные
+	now         func() time.Time
+	obs         Observer // наблюдатель чтения, nil - выключен
+	mapped      bool     // mdata отображён из файла (mmap), к нему применимы madvise и mlock
+	advice      Advice
+	seqScan     uint64
+	scans       scanAdvice // активные долгие обходы (OpenOptions.SequentialScan)
+	meta        map[string][]byte
+	indexes     map[string]*Index // вторичные индексы по имени
+	spaces      map[string]*MMAPDB
+	spaceHash   []byte // у пространства имён: хэш содержимого общего файла вместе с именем
 }
 
+// maxDecoded - предел распакованного размера: значения длиннее narrowLimit
+// хранятся без сжатия, а длина блока - uint32.
+const maxDecoded = 1 << 32
+
 // Глобальный zstd-декодер для быстрой распаковки
-var zstdDec, _ = zstd.NewReader(nil)
+var zstdDec, _ = zstd.NewReader(nil, zstd.WithDecoderMaxMemory(maxDecoded))
 
 // New создаёт новое адаптивное радикс-дерево (ART) в памяти.
 func New() art.Tree {
@@ -73,6 +156,11 @@ func New() art.Tree {
 
 // Open открывает базу данных из указанного пути.
 func Open(path string) (*MMAPDB, error) {
+	return OpenWithOptions(path, OpenOptions{})
+}
+
+// OpenWithOptions открывает базу данных из указанного пути с дополнительными настройками чтения.
+func OpenWithOptions(path string, opts OpenOptions) (*MMAPDB, error) {
 	f, err := os.Open(path)
 	if err != nil {
 		return nil, err
@@ -84,58 +172,269 @@ func Open(path string) (*MMAPDB, error) {
 		return nil, err
 	}
 
-	if len(m) < int(headerSize) {
-		_ = m.Unmap()
-		return nil, errors.New("слишком короткий файл")
+	db := &MMAPDB{mdata: m, size: uint64(len(m)), closer: m.Unmap, mapped: true}
+	if err := db.init(opts); err != nil {
+		_ = db.Close()
+		return nil, err
 	}
+	if err := db.applyPaging(opts); err != nil {
+		_ = db.Close()
+		return nil, err
+	}
+	return db, nil
+}
 
+// parseHeader разбирает и проверяет сигнатуру заголовка файла.
+func parseHeader(b []byte) (fileHeader, error) {
 	var hdr fileHeader
-	copy(hdr.Magic[:], m[0:8])
+	copy(hdr.Magic[:], b[0:8])
 	if string(hdr.Magic[:]) != FileMagic {
-		_ = m.Unmap()
-		return nil, errors.New("неверная сигнатура файла (magic)")
+		return hdr, errors.New("неверная сигнатура файла (magic)")
+	}
+
+	hdr.Version = binary.LittleEndian.Uint32(b[8:12])
+	hdr.Flags = binary.LittleEndian.Uint32(b[12:16])
+	hdr.NumEntries = binary.LittleEndian.Uint64(b[16:24])
+	hdr.OffIndex = binary.LittleEndian.Uint64(b[24:32])
+	hdr.OffBlobs = binary.LittleEndian.Uint64(b[32:40])
+	hdr.ValueFmt = binary.LittleEndian.Uint32(b[40:44])
+	hdr.Compression = binary.LittleEndian.Uint32(b[44:48])
+	hdr.OffSections = binary.LittleEndian.Uint64(b[48:56])
+	hdr.NumSections = binary.LittleEndian.Uint32(b[56:60])
+
+	if hdr.Version > FileVersion {
+		return hdr, fmt.Errorf("неподдерживаемая версия формата: %d", hdr.Version)
+	}
+	return hdr, nil
+}
+
+// encode сериализует заголовок в headerSize байт.
+func (hdr *fileHeader) encode() []byte {
+	b := make([]byte, headerSize)
+	copy(b[0:8], hdr.Magic[:])
+	binary.LittleEndian.PutUint32(b[8:12], hdr.Version)
+	binary.LittleEndian.PutUint32(b[12:16], hdr.Flags)
+	binary.LittleEndian.PutUint64(b[16:24], hdr.NumEntries)
+	binary.LittleEndian.PutUint64(b[24:32], hdr.OffIndex)
+	binary.LittleEndian.PutUint64(b[32:40], hdr.OffBlobs)
+	binary.LittleEndian.PutUint32(b[40:44], hdr.ValueFmt)
+	binary.LittleEndian.PutUint32(b[44:48], hdr.Compression)
+	binary.LittleEndian.PutUint64(b[48:56], hdr.OffSections)
+	binary.LittleEndian.PutUint32(b[56:60], hdr.NumSections)
+	return b
+}
+
+// init читает заголовок, секции и готовит базу к чтению. Источник данных уже задан.
+func (db *MMAPDB) init(opts OpenOptions) error {
+	if db.size < headerSize {
+		return errors.New("слишком короткий файл")
+	}
+	hb := db.at(0, headerSize)
+	if hb == nil {
+		return errors.New("ошибка чтения заголовка")
+	}
+	hdr, err := parseHeader(hb)
+	if err != nil {
+		return err
 	}
 
-	hdr.Version = binary.LittleEndian.Uint32(m[8:12])
-	hdr.NumEntries = binary.LittleEndian.Uint64(m[16:24])
-	hdr.OffIndex = binary.LittleEndian.Uint64(m[24:32])
-	hdr.OffBlobs = binary.LittleEndian.Uint64(m[32:40])
-	hdr.ValueFmt = binary.LittleEndian.Uint32(m[40:44])
-	hdr.Compression = binary.LittleEndian.Uint32(m[44:48])
+	// Файл, записанный потоком (BuildTo), хранит заголовок в конце.
+	if hdr.Flags&flagFooter != 0 {
+		if db.size < 2*headerSize {
+			return errors.New("слишком короткий файл")
+		}
+		hb = db.at(db.size-headerSize, headerSize)
+		if hb == nil {
+			return errors.New("ошибка чтения заголовка")
+		}
+		if hdr, err = parseHeader(hb); err != nil {
+			return err
+		}
+	}
+	return db.setup(hdr, opts)
+}
 
+// setup проверяет заголовок и загружает секции. Для пространств имён вызывается
+// с заголовком из таблицы пространств.
+func (db *MMAPDB) setup(hdr fileHeader, opts OpenOptions) error {
 	// Проверка границ индекса
-	indexTotalSize := hdr.NumEntries * indexEntrySize
-	if hdr.OffIndex > uint64(len(m)) || indexTotalSize > uint64(len(m)) || hdr.OffIndex+indexTotalSize > uint64(len(m)) {
-		_ = m.Unmap()
-		return nil, errors.New("некорректный размер индекса или смещение")
+	db.indexSize = indexEntrySize
+	if hdr.Flags&flagWide != 0 {
+		db.indexSize = wideEntrySize
+	}
+	if hdr.NumEntries > db.size/db.indexSize {
+		return errors.New("некорректный размер индекса или смещение")
+	}
+	indexTotalSize := hdr.NumEntries * db.indexSize
+	if hdr.OffIndex > db.size || indexTotalSize > db.size || hdr.OffIndex+indexTotalSize > db.size {
+		return errors.New("некорректный размер индекса или смещение")
 	}
 
 	// Проверка корректности типа сжатия
 	if hdr.Compression > compS2 {
-		_ = m.Unmap()
-		return nil, fmt.Errorf("неподдерживаемый тип сжатия: %d", hdr.Compression)
+		return fmt.Errorf("неподдерживаемый тип сжатия: %d", hdr.Compression)
 	}
 
-	db := &MMAPDB{
-		mdata:       m,
-		hdr:         hdr,
-		indexBase:   hdr.OffIndex,
-		indexSize:   indexEntrySize,
-		num:         hdr.NumEntries,
-		compression: hdr.Compression,
+	db.hdr = hdr
+	db.obs = opts.Observer
+	db.indexBase = hdr.OffIndex
+	db.num = hdr.NumEntries
+	db.compression = hdr.Compression
+
+	if err := db.loadSections(); err != nil {
+		return err
 	}
 
-	return db, nil
+	if d := db.section(secZstdDict); d != nil {
+		dec, err := zstd.NewReader(nil, zstd.WithDecoderDicts(d), zstd.WithDecoderMaxMemory(maxDecoded))
+		if err != nil {
+			return fmt.Errorf("ошибка загрузки словаря zstd: %w", err)
+		}
+		db.zdec = dec
+	}
+
+	if hdr.Flags&flagBlocks != 0 {
+		db.blocks = db.section(secBlocks)
+		if db.blocks == nil || uint64(len(db.blocks))%blockRefSize != 0 {
+			return errors.New("некорректная таблица блоков")
+		}
+	}
+
+	if hdr.Flags&flagDelta != 0 {
+		db.tombs = db.section(secTombs)
+		if uint64(len(db.tombs)) < (db.num+7)/8 {
+			return errors.New("некорректная карта удалённых записей")
+		}
+	}
+
+	if e := db.section(secExpiry); e != nil {
+		if uint64(len(e)) != db.num*8 {
+			return errors.New("некорректная таблица сроков действия")
+		}
+		db.expires = e
+		db.now = opts.Now
+		if db.now == nil {
+			db.now = time.Now
+		}
+	}
+
+	if m := db.section(secMeta); m != nil {
+		meta, err := decodeMetadata(m)
+		if err != nil {
+			return err
+		}
+		db.meta = meta
+	}
+
+	for _, s := range db.sections {
+		if s.kind != secIndex {
+			continue
+		}
+		idx, err := db.parseIndex(db.at(s.off, s.size))
+		if err != nil {
+			return err
+		}
+		if db.indexes == nil {
+			db.indexes = make(map[string]*Index)
+		}
+		db.indexes[idx.name] = idx
+	}
+
+	if t := db.section(secSpaces); t != nil {
+		if err := db.loadSpaces(t, opts); err != nil {
+			return err
+		}
+	}
+
+	// Кэш значений нужен только там, где есть что распаковывать: блочная раскладка
+	// держит свой кэш блоков, а несжатые файлы отдают срезы mmap напрямую.
+	if opts.CacheBytes > 0 && db.blocks == nil && hdr.Flags&flagUncompressed == 0 {
+		db.vcache = newValueCache(opts.CacheBytes)
+	}
+	return nil
+}
+
+// loadSections читает таблицу секций и проверяет их границы.
+func (db *MMAPDB) loadSections() error {
+	if db.hdr.OffSections == 0 {
+		return nil
+	}
+	table := db.at(db.hdr.OffSections, uint64(db.hdr.NumSections)*sectionEntrySize)
+	if table == nil {
+		return errors.New("некорректная таблица секций")
+	}
+	db.sections = make([]section, 0, db.hdr.NumSections)
+	for off := uint64(0); off < uint64(len(table)); off += sectionEntrySize {
+		s := section{
+			kind: binary.LittleEndian.Uint32(table[off : off+4]),
+			off:  binary.LittleEndian.Uint64(table[off+8 : off+16]),
+			size: binary.LittleEndian.Uint64(table[off+16 : off+24]),
+		}
+		if s.off > db.size || s.size > db.size || s.off+s.size > db.size {
+			return fmt.Errorf("секция %d выходит за границы файла", s.kind)
+		}
+		db.sections = append(db.sections, s)
+	}
+	return nil
+}
+
+// section возвращает содержимое первой секции указанного типа или nil.
+func (db *MMAPDB) section(kind uint32) []byte {
+	for _, s := range db.sections {
+		if s.kind == kind {
+			return db.at(s.off, s.size)
+		}
+	}
+	return nil
+}
+
+// ContentHash возвращает SHA-256 содержимого файла, записанный при сборке,
+// или nil для файлов без хэша. Хэш не зависит от положения заголовка.
+func (db *MMAPDB) ContentHash() []byte {
+	h := db.section(secHash)
+	if len(h) != sha256.Size {
+		return nil
+	}
+	return append([]byte(nil), h...)
+}
+
+// at возвращает n байт файла начиная со смещения off или nil, если диапазон
+// выходит за границы файла (или не удалось прочитать его из io.ReaderAt).
+func (db *MMAPDB) at(off, n uint64) []byte {
+	if off > db.size || n > db.size || off+n > db.size {
+		return nil
+	}
+	if db.ra != nil {
+		return db.ra.slice(off, n)
+	}
+	return db.mdata[off : off+n]
 }
 
 // Close закрывает базу данных и освобождает mmap.
 func (db *MMAPDB) Close() error {
-	return db.mdata.Unmap()
+	if db.zdec != nil {
+		db.zdec.Close()
+	}
+	for _, ns := range db.spaces {
+		ns.Close()
+	}
+	if db.closer != nil {
+		return db.closer()
+	}
+	return nil
 }
 
 // Get выполняет поиск ключа и возвращает сырые данные (указывает прямо в mmap).
 func (db *MMAPDB) GetRaw(key []byte) ([]byte, bool) {
-	idx, ok := db.findIndex(key)
+	v, ok := db.getRaw(key)
+	if db.obs != nil {
+		db.obs.OnLookup(OpGetRaw, ok, len(v))
+	}
+	return v, ok
+}
+
+func (db *MMAPDB) getRaw(key []byte) ([]byte, bool) {
+	idx, ok := db.lookup(key)
 	if !ok {
 		return nil, false
 	}
@@ -147,19 +446,123 @@ func (db *MMAPDB) GetRaw(key []byte) ([]byte, bool) {
 }
 
 // Find возвращает распакованное значение в dst.
+// Если при открытии включён кэш значений (OpenOptions.CacheBytes), при попадании
+// возвращается срез из кэша - его нельзя изменять.
 func (db *MMAPDB) Find(key []byte, dst []byte) ([]byte, bool, error) {
-	val, ok := db.GetRaw(key)
+	v, ok, err := db.find(key, dst)
+	if rerr := db.readErr(); rerr != nil {
+		v, ok, err = nil, false, rerr
+	}
+	if db.obs != nil {
+		db.obs.OnLookup(OpFind, ok, len(v))
+	}
+	return v, ok, err
+}
+
+func (db *MMAPDB) find(key []byte, dst []byte) ([]byte, bool, error) {
+	idx, ok := db.lookup(key)
+	if !ok {
+		return nil, false, nil
+	}
+	if db.vcache == nil {
+		return db.valueAt(idx, dst)
+	}
+	if v, ok := db.vcache.get(idx); ok {
+		return v, true, nil
+	}
+	out, ok, err := db.valueAt(idx, dst)
+	if ok && err == nil {
+		db.vcache.put(idx, out)
+	}
+	return out, ok, err
+}
+
+// valueAt возвращает распакованное значение записи i.
+// ok=false означает, что значение выходит за границы файла.
+func (db *MMAPDB) valueAt(i uint64, dst []byte) ([]byte, bool, error) {
+	if db.blocks != nil {
+		v, err := db.blockValue(i)
+		return v, true, err
+	}
+	e, ok := db.readIndex(i)
 	if !ok {
 		return nil, false, nil
 	}
-	out, err := db.decode(val, dst)
+	raw := db.at(e.voff, e.vlen)
+	if raw == nil {
+		return nil, false, nil
+	}
+	if e.raw {
+		return raw, true, nil
+	}
+	if db.obs == nil {
+		out, err := db.decode(raw, dst)
+		return out, true, err
+	}
+	start := time.Now()
+	out, err := db.decode(raw, dst)
+	db.obs.OnDecode(len(out), time.Since(start), err)
 	return out, true, err
 }
 
+// value возвращает распакованное значение записи i; отсутствие значения - ошибка.
+// Пустое значение возвращается как непустой срез нулевой длины.
+func (db *MMAPDB) value(i uint64) ([]byte, error) {
+	v, ok, err := db.valueAt(i, nil)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, errCorruptValue
+	}
+	if v == nil {
+		v = []byte{}
+	}
+	return v, nil
+}
+
+// ValueReader возвращает значение ключа для чтения по частям. Несжатые значения
+// (в том числе огромные значения широкого индекса) читаются прямо из источника
+// без копирования в память; сжатые распаковываются целиком.
+func (db *MMAPDB) ValueReader(key []byte) (*io.SectionReader, bool, error) {
+	idx, ok := db.lookup(key)
+	if !ok {
+		return nil, false, nil
+	}
+	if db.blocks == nil {
+		e, ok := db.readIndex(idx)
+		if !ok {
+			return nil, false, errCorruptValue
+		}
+		if e.raw || db.hdr.Flags&flagUncompressed != 0 {
+			if e.voff > db.size || e.vlen > db.size-e.voff {
+				return nil, false, errCorruptValue
+			}
+			return io.NewSectionReader(db.source(), int64(e.voff), int64(e.vlen)), true, nil
+		}
+	}
+	v, err := db.value(idx)
+	if err != nil {
+		return nil, false, err
+	}
+	return io.NewSectionReader(bytes.NewReader(v), 0, int64(len(v))), true, nil
+}
+
+// source возвращает файл базы как io.ReaderAt.
+func (db *MMAPDB) source() io.ReaderAt {
+	if db.ra != nil {
+		return db.ra.r
+	}
+	return bytes.NewReader(db.mdata)
+}
+
 func (db *MMAPDB) decode(val []byte, dst []byte) ([]byte, error) {
+	if db.hdr.Flags&flagUncompressed != 0 {
+		return val, nil
+	}
 	switch db.compression {
 	case compZstd:
-		return zstdDec.DecodeAll(val, dst[:0])
+		return db.zstdDecoder().DecodeAll(val, dst[:0])
 	case compS2:
 		return s2.Decode(dst[:0], val)
 	case 0:
@@ -168,7 +571,7 @@ func (db *MMAPDB) decode(val []byte, dst []byte) ([]byte, error) {
 		if err == nil {
 			return out, nil
 		}
-		out, err = zstdDec.DecodeAll(val, dst[:0])
+		out, err = db.zstdDecoder().DecodeAll(val, dst[:0])
 		if err == nil {
 			return out, nil
 		}
@@ -178,48 +581,133 @@ func (db *MMAPDB) decode(val []byte, dst []byte) ([]byte, error) {
 	}
 }
 
+// zstdDecoder возвращает декодер со словарём файла, если он есть, иначе общий.
+func (db *MMAPDB) zstdDecoder() *zstd.Decoder {
+	if db.zdec != nil {
+		return db.zdec
+	}
+	return zstdDec
+}
+
 // PrefixRaw перебирает все ключи, начинающиеся с prefix.
 func (db *MMAPDB) PrefixRaw(prefix []byte, cb func(key, val []byte) bool) {
-	idx, _ := db.findIndex(prefix)
-	for i := idx; i < db.num; i++ {
-		k := db.getKeySlice(i)
-		if k == nil || !bytes.HasPrefix(k, prefix) {
-			break
-		}
-		v := db.getValSlice(i)
-		if v == nil {
-			break
-		}
-		if !cb(k, v) {
-			break
-		}
+	if db.obs != nil {
+		var done func()
+		cb, done = db.observeScan(OpPrefixRaw, cb)
+		defer done()
 	}
+	db.scanRaw(prefix, hasPrefix(prefix), cb)
 }
 
 // Prefix похож на PrefixRaw, но распаковывает значения.
 func (db *MMAPDB) Prefix(prefix []byte, dst []byte, cb func(key, val []byte) bool) error {
-	idx, _ := db.findIndex(prefix)
+	if db.obs != nil {
+		var done func()
+		cb, done = db.observeScan(OpPrefix, cb)
+		defer done()
+	}
+	return db.scanValues(prefix, hasPrefix(prefix), dst, cb)
+}
+
+// RangeRaw перебирает ключи из полуинтервала [lo, hi). hi == nil - до конца базы.
+func (db *MMAPDB) RangeRaw(lo, hi []byte, cb func(key, val []byte) bool) {
+	if db.obs != nil {
+		var done func()
+		cb, done = db.observeScan(OpRangeRaw, cb)
+		defer done()
+	}
+	db.scanRaw(lo, below(hi), cb)
+}
+
+// Range похож на RangeRaw, но распаковывает значения.
+func (db *MMAPDB) Range(lo, hi []byte, dst []byte, cb func(key, val []byte) bool) error {
+	if db.obs != nil {
+		var done func()
+		cb, done = db.observeScan(OpRange, cb)
+		defer done()
+	}
+	return db.scanValues(lo, below(hi), dst, cb)
+}
+
+func hasPrefix(prefix []byte) func(k []byte) bool {
+	return func(k []byte) bool { return bytes.HasPrefix(k, prefix) }
+}
+
+func below(hi []byte) func(k []byte) bool {
+	return func(k []byte) bool { return hi == nil || bytes.Compare(k, hi) < 0 }
+}
+
+// scan обходит записи начиная с первого ключа >= lo, пока inRange истинно,
+// пропуская скрытые записи. Обход прекращается, когда fn возвращает false.
+func (db *MMAPDB) scan(lo []byte, inRange func(k []byte) bool, fn func(i uint64, k []byte) bool) {
+	db.scanAll(lo, inRange, func(i uint64, k []byte) bool {
+		return db.hidden(i) || fn(i, k)
+	})
+}
+
+// scanAll похож на scan, но передаёт fn и скрытые записи.
+func (db *MMAPDB) scanAll(lo []byte, inRange func(k []byte) bool, fn func(i uint64, k []byte) bool) {
+	idx, _ := db.findIndex(lo)
 	for i := idx; i < db.num; i++ {
+		if db.seqScan > 0 && i-idx == db.seqScan {
+			defer db.adviseScan(i)()
+		}
 		k := db.getKeySlice(i)
-		if k == nil || !bytes.HasPrefix(k, prefix) {
+		if k == nil || !inRange(k) {
 			break
 		}
-		valRaw := db.getValSlice(i)
-		if valRaw == nil {
+		if !fn(i, k) {
 			break
 		}
-		valDec, err := db.decode(valRaw, dst)
-		if err != nil {
-			return err
+	}
+}
+
+func (db *MMAPDB) scanRaw(lo []byte, inRange func(k []byte) bool, cb func(key, val []byte) bool) {
+	db.scan(lo, inRange, func(i uint64, k []byte) bool {
+		v := db.getValSlice(i)
+		if v == nil {
+			return false
 		}
-		if !cb(k, valDec) {
-			break
+		return cb(k, v)
+	})
+}
+
+func (db *MMAPDB) scanValues(lo []byte, inRange func(k []byte) bool, dst []byte, cb func(key, val []byte) bool) error {
+	var err error
+	db.scan(lo, inRange, func(i uint64, k []byte) bool {
+		v, ok, e := db.valueAt(i, dst)
+		if !ok {
+			return false
 		}
+		if e != nil {
+			err = e
+			return false
+		}
+		return cb(k, v)
+	})
+	if err == nil {
+		err = db.readErr()
 	}
-	return nil
+	return err
 }
 
-// findIndex выполняет бинарный поиск индекса по ключу.
+// lookup ищет запись по ключу, не считая скрытые записи.
+func (db *MMAPDB) lookup(key []byte) (uint64, bool) {
+	idx, ok := db.findIndex(key)
+	if !ok || db.hidden(idx) {
+		return 0, false
+	}
+	return idx, true
+}
+
+// hidden сообщает, что запись i не видна читателям (удалена в дельта-файле
+// или истёк её срок действия).
+func (db *MMAPDB) hidden(i uint64) bool {
+	return db.deleted(i) || db.expired(i)
+}
+
+// findIndex возвращает позицию первой записи с ключом >= key (lower bound) и признак
+// точного совпадения. Для повторяющихся ключей это первая запись группы.
 func (db *MMAPDB) findIndex(key []byte) (uint64, bool) {
 	var lo, hi uint64 = 0, db.num
 	for lo < hi {
@@ -229,190 +717,60 @@ func (db *MMAPDB) findIndex(key []byte) (uint64, bool) {
 			// Поврежденный индекс
 			return 0, false
 		}
-		cmp := bytes.Compare(k, key)
-		if cmp == 0 {
-			return mid, true
-		} else if cmp < 0 {
+		if bytes.Compare(k, key) < 0 {
 			lo = mid + 1
 		} else {
 			hi = mid
 		}
 	}
+	if lo < db.num {
+		k := db.getKeySlice(lo)
+		return lo, k != nil && bytes.Equal(k, key)
+	}
 	return lo, false
 }
 
-func (db *MMAPDB) readIndex(i uint64) (koff uint64, klen uint32, voff uint64, vlen uint32) {
-	off := db.indexBase + i*indexEntrySize
-	koff = binary.LittleEndian.Uint64(db.mdata[off : off+8])
-	klen = binary.LittleEndian.Uint32(db.mdata[off+8 : off+12])
-	voff = binary.LittleEndian.Uint64(db.mdata[off+12 : off+20])
-	vlen = binary.LittleEndian.Uint32(db.mdata[off+20 : off+24])
-	return
+func (db *MMAPDB) readIndex(i uint64) (e indexEntry, ok bool) {
+	b := db.at(db.indexBase+i*db.indexSize, db.indexSize)
+	if b == nil {
+		return e, false
+	}
+	e.koff = binary.LittleEndian.Uint64(b[0:8])
+	e.klen = binary.LittleEndian.Uint32(b[8:12])
+	if db.indexSize == wideEntrySize {
+		e.raw = binary.LittleEndian.Uint32(b[12:16])&entryRaw != 0
+		e.voff = binary.LittleEndian.Uint64(b[16:24])
+		e.vlen = binary.LittleEndian.Uint64(b[24:32])
+		return e, true
+	}
+	e.voff = binary.LittleEndian.Uint64(b[12:20])
+	e.vlen = uint64(binary.LittleEndian.Uint32(b[20:24]))
+	return e, true
 }
 
 func (db *MMAPDB) getKeySlice(i uint64) []byte {
-	koff, klen, _, _ := db.readIndex(i)
-	if koff > uint64(len(db.mdata)) || uint64(klen) > uint64(len(db.mdata)) || koff+uint64(klen) > uint64(len(db.mdata)) {
+	e, ok := db.readIndex(i)
+	if !ok {
 		return nil
 	}
-	return db.mdata[koff : koff+uint64(klen)]
+	return db.at(e.koff, uint64(e.klen))
 }
 
+// getValSlice возвращает хранимое значение записи i. В блочной раскладке это
+// срез распакованного блока, в обычной - срез mmap.
 func (db *MMAPDB) getValSlice(i uint64) []byte {
-	_, _, voff, vlen := db.readIndex(i)
-	if voff > uint64(len(db.mdata)) || uint64(vlen) > uint64(len(db.mdata)) || voff+uint64(vlen) > uint64(len(db.mdata)) {
-		return nil
-	}
-	return db.mdata[voff : voff+uint64(vlen)]
-}
-
-// BuildOptions управляет настройками компрессии при сборке базы.
-type BuildOptions struct {
-	Compression uint32 // 0=auto, 1=zstd, 2=s2
-	ZstdLevel   int    // 1..3 уровни скорости
-	SizeCutover int    // порог выбора между s2 и zstd для режима auto
-}
-
-// BuildWithOptions сериализует ART дерево в файл с заданными опциями.
-func BuildWithOptions(tree art.Tree, path string, opts BuildOptions) error {
-	dir := filepath.Dir(path)
-	if err := os.MkdirAll(dir, 0o755); err != nil {
-		return fmt.Errorf("ошибка создания директории %s: %w", dir, err)
-	}
-
-	var num uint64
-	tree.ForEach(func(n art.Node) (cont bool) {
-		num++
-		return true
-	}, art.TraverseLeaf)
-
-	offIndex := uint64(headerSize)
-	indexSize := num * indexEntrySize
-	offBlobs := offIndex + indexSize
-
-	tmp := path + ".tmp"
-	f, err := os.Create(tmp)
-	if err != nil {
-		return fmt.Errorf("ошибка создания временного файла: %w", err)
-	}
-	defer f.Close()
-
-	if _, err := f.Write(make([]byte, headerSize)); err != nil {
-		return fmt.Errorf("ошибка записи заглушки заголовка: %w", err)
-	}
-
-	if _, err := f.Seek(int64(offBlobs), io.SeekStart); err != nil {
-		return fmt.Errorf("ошибка перехода к области данных: %w", err)
-	}
-
-	const alignTo = 8
-
-	type idx struct {
-		koff uint64
-		klen uint32
-		voff uint64
-		vlen uint32
-	}
-	indices := make([]idx, 0, num)
-
-	compression := opts.Compression
-	if compression == 0 {
-		// Режим авто - по умолчанию S2, но для конкретных блоков может быть Zstd
-		compression = 0
-	}
-
-	var zenc *zstd.Encoder
-	if compression == compZstd {
-		level := zstd.SpeedFastest
-		if opts.ZstdLevel == 2 {
-			level = zstd.SpeedDefault
-		} else if opts.ZstdLevel == 3 {
-			level = zstd.SpeedBetterCompression
+	if db.blocks != nil {
+		v, err := db.blockValue(i)
+		if err != nil {
+			return nil
 		}
-		zenc, _ = zstd.NewWriter(nil, zstd.WithEncoderLevel(level))
-		defer zenc.Close()
+		return v
 	}
-
-	tree.ForEach(func(n art.Node) (cont bool) {
-		k := n.Key()
-		v := n.Value()
-		var vb []byte
-		switch vv := v.(type) {
-		case []byte:
-			vb = vv
-		case string:
-			vb = []byte(vv)
-		default:
-			vb = []byte(fmt.Sprint(vv))
-		}
-
-		off, _ := f.Seek(0, io.SeekCurrent)
-		koff := uint64(off)
-		_, _ = f.Write(k)
-		klen := uint32(len(k))
-
-		off2, _ := f.Seek(0, io.SeekCurrent)
-		voff := uint64(off2)
-
-		var cv []byte
-		compToUse := compression
-		if opts.Compression == 0 && opts.SizeCutover > 0 {
-			if len(vb) > opts.SizeCutover {
-				compToUse = compZstd
-			} else {
-				compToUse = compS2
-			}
-		}
-
-		switch compToUse {
-		case compZstd:
-			// Для Zstd в режиме авто создадим энкодер если его нет
-			if zenc == nil {
-				zenc, _ = zstd.NewWriter(nil, zstd.WithEncoderLevel(zstd.SpeedFastest))
-				defer zenc.Close()
-			}
-			cv = zenc.EncodeAll(vb, nil)
-		case compS2:
-			cv = s2.Encode(nil, vb)
-		default:
-			cv = vb
-		}
-		_, _ = f.Write(cv)
-		vlen := uint32(len(cv))
-
-		indices = append(indices, idx{koff, klen, voff, vlen})
-		return true
-	}, art.TraverseLeaf)
-
-	_, _ = f.Seek(int64(offIndex), io.SeekStart)
-	recBuf := make([]byte, indexEntrySize)
-	for _, it := range indices {
-		binary.LittleEndian.PutUint64(recBuf[0:8], it.koff)
-		binary.LittleEndian.PutUint32(recBuf[8:12], it.klen)
-		binary.LittleEndian.PutUint64(recBuf[12:20], it.voff)
-		binary.LittleEndian.PutUint32(recBuf[20:24], it.vlen)
-		_, _ = f.Write(recBuf)
-	}
-
-	_, _ = f.Seek(0, io.SeekStart)
-	hdrBuf := make([]byte, headerSize)
-	copy(hdrBuf[0:8], FileMagic)
-	binary.LittleEndian.PutUint32(hdrBuf[8:12], FileVersion)
-	binary.LittleEndian.PutUint64(hdrBuf[16:24], num)
-	binary.LittleEndian.PutUint64(hdrBuf[24:32], offIndex)
-	binary.LittleEndian.PutUint64(hdrBuf[32:40], offBlobs)
-	binary.LittleEndian.PutUint32(hdrBuf[40:44], 100)
-	binary.LittleEndian.PutUint32(hdrBuf[44:48], compression)
-	_, _ = f.Write(hdrBuf)
-
-	_ = f.Sync()
-	_ = f.Close()
-	return os.Rename(tmp, path)
-}
-
-// Build — обёртка над BuildWithOptions с параметрами по умолчанию.
-func Build(tree art.Tree, path string) error {
-	return BuildWithOptions(tree, path, BuildOptions{Compression: 0, ZstdLevel: 1, SizeCutover: 256})
+	e, ok := db.readIndex(i)
+	if !ok {
+		return nil
+	}
+	return db.at(e.voff, e.vlen)
 }
 
 // ZipEncrypt сжимает и шифрует файл srcPath, записывая результат в dstPath с использованием masterKey.
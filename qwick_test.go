@@ -4,8 +4,11 @@ import (
 	"bytes"
 	"encoding/binary"
 	"fmt"
+	"io"
 	"os"
 	"path/filepath"
+	"reflect"
+	"strings"
 	"testing"
 )
 
@@ -567,3 +570,120 @@ func TestZipUnzip(t *testing.T) {
 		t.Error("Empty file decryption should result in empty file")
 	}
 }
+
+func TestWideValues(t *testing.T) {
+	// Понижаем порог, чтобы не создавать значения больше 4GB.
+	defer func(old uint64) { narrowLimit = old }(narrowLimit)
+	narrowLimit = 64
+
+	dir := t.TempDir()
+	big := strings.Repeat("большое значение ", 20)
+	kv := map[string]string{"a": "short", "big": big, "c": strings.Repeat("z", 40)}
+
+	for _, c := range []uint32{0, 1, 2} {
+		path := filepath.Join(dir, fmt.Sprintf("wide%d.qwick", c))
+		db := buildTestDB(t, path, kv, BuildOptions{Compression: c, SizeCutover: 8})
+		if db.hdr.Flags&flagWide == 0 || db.hdr.Version != FileVersion {
+			t.Fatalf("compression %d: ожидался широкий индекс, флаги %b, версия %d", c, db.hdr.Flags, db.hdr.Version)
+		}
+		if got := dumpDB(t, db); !reflect.DeepEqual(got, kv) {
+			t.Fatalf("compression %d: содержимое %v", c, got)
+		}
+		if raw, ok := db.GetRaw([]byte("big")); !ok || string(raw) != big {
+			t.Errorf("compression %d: большое значение должно храниться несжатым", c)
+		}
+
+		for k, want := range kv {
+			r, ok, err := db.ValueReader([]byte(k))
+			if err != nil || !ok {
+				t.Fatalf("ValueReader(%q): ok=%v err=%v", k, ok, err)
+			}
+			got, err := io.ReadAll(r)
+			if err != nil || string(got) != want {
+				t.Errorf("ValueReader(%q) = %q, %v", k, got, err)
+			}
+		}
+		r, _, _ := db.ValueReader([]byte("big"))
+		part := make([]byte, 10)
+		if _, err := r.ReadAt(part, 5); err != nil || string(part) != big[5:15] {
+			t.Errorf("ReadAt = %q, %v", part, err)
+		}
+		if _, ok, err := db.ValueReader([]byte("missing")); ok || err != nil {
+			t.Errorf("ValueReader отсутствующего ключа: ok=%v err=%v", ok, err)
+		}
+	}
+
+	// Обычные файлы остаются в версии 2.
+	db := buildTestDB(t, filepath.Join(dir, "narrow.qwick"), map[string]string{"a": "b"}, BuildOptions{})
+	if db.hdr.Flags&flagWide != 0 || db.hdr.Version != 2 {
+		t.Errorf("узкий файл: флаги %b, версия %d", db.hdr.Flags, db.hdr.Version)
+	}
+
+	// Блочная раскладка не умеет огромные значения.
+	tree := New()
+	tree.Insert([]byte("big"), []byte(big))
+	err := BuildWithOptions(tree, filepath.Join(dir, "blocks.qwick"), BuildOptions{Layout: LayoutBlocks})
+	if err == nil || !strings.Contains(err.Error(), "LayoutValues") {
+		t.Errorf("ожидалась ошибка блочной раскладки, получено %v", err)
+	}
+
+	// Значения по отдельности помещаются, но вместе переполнили бы 32-битный блок:
+	// блок сбрасывается заранее.
+	kv = map[string]string{}
+	for i := range 10 {
+		kv[fmt.Sprintf("k%d", i)] = strings.Repeat(string(rune('a'+i)), 40)
+	}
+	bdb := buildTestDB(t, filepath.Join(dir, "blocks.qwick"), kv, BuildOptions{Layout: LayoutBlocks, BlockSize: 1 << 20})
+	if uint64(len(bdb.blocks)) != 10*blockRefSize {
+		t.Errorf("ожидалось 10 блоков, таблица %d байт", len(bdb.blocks))
+	}
+	if got := dumpDB(t, bdb); !reflect.DeepEqual(got, kv) {
+		t.Errorf("блоки: содержимое %v", got)
+	}
+
+	// Сжатый блок тоже должен помещаться в 32 бита.
+	tree = New()
+	tree.Insert([]byte("rnd"), []byte("0123456789abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ!?"[:60]))
+	err = BuildWithOptions(tree, filepath.Join(dir, "zblocks.qwick"), BuildOptions{Layout: LayoutBlocks, Compression: compZstd})
+	if err == nil || !strings.Contains(err.Error(), "BlockSize") {
+		t.Errorf("ожидалась ошибка размера сжатого блока, получено %v", err)
+	}
+}
+
+func TestWideMergePatch(t *testing.T) {
+	defer func(old uint64) { narrowLimit = old }(narrowLimit)
+	narrowLimit = 64
+
+	dir := t.TempDir()
+	big := strings.Repeat("x", 200)
+	a := buildTestDB(t, filepath.Join(dir, "a.qwick"), map[string]string{"k1": big, "k2": "v2"}, BuildOptions{Compression: 1})
+	b := buildTestDB(t, filepath.Join(dir, "b.qwick"), map[string]string{"k2": "new", "k3": big + "y"}, BuildOptions{Compression: 1})
+
+	merged := filepath.Join(dir, "m.qwick")
+	if err := Merge(merged, []*MMAPDB{a, b}, LastWins); err != nil {
+		t.Fatalf("Ошибка Merge: %v", err)
+	}
+	m, err := Open(merged)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer m.Close()
+	want := map[string]string{"k1": big, "k2": "new", "k3": big + "y"}
+	if got := dumpDB(t, m); !reflect.DeepEqual(got, want) {
+		t.Fatalf("Merge: %v", got)
+	}
+
+	var changes int
+	if err := Diff(a, b, func(Change) bool { changes++; return true }); err != nil || changes != 3 {
+		t.Errorf("Diff: %d изменений, %v", changes, err)
+	}
+
+	patch := filepath.Join(dir, "p.qpatch")
+	if err := MakePatch(filepath.Join(dir, "a.qwick"), merged, patch); err != nil {
+		t.Fatalf("Ошибка MakePatch: %v", err)
+	}
+	out := filepath.Join(dir, "out.qwick")
+	if err := ApplyPatch(filepath.Join(dir, "a.qwick"), patch, out); err != nil {
+		t.Fatalf("Ошибка ApplyPatch: %v", err)
+	}
+}
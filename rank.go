@@ -0,0 +1,94 @@
+package qwick
+
+import (
+	"bytes"
+	"math/bits"
+
+	"github.com/globalmac/qwick/keyenc"
+)
+
+// Порядковые операции работают с номерами записей отсортированного индекса:
+// номер i - позиция записи в индексе, от 0 до Len()-1. Надгробия дельта-файлов
+// и истёкшие записи занимают номера, но не учитываются в подсчётах.
+
+// Len возвращает число записей индекса (включая надгробия и повторы ключей).
+func (db *MMAPDB) Len() uint64 {
+	return db.num
+}
+
+// Rank возвращает номер записи с ключом key или, если ключа нет, позицию, куда
+// он был бы вставлен. found сообщает, что ключ есть в индексе. O(log n).
+func (db *MMAPDB) Rank(key []byte) (i uint64, found bool) {
+	return db.findIndex(key)
+}
+
+// KeyAt возвращает ключ записи i (срез указывает в файл). ok=false, если номер
+// вне индекса или запись скрыта.
+func (db *MMAPDB) KeyAt(i uint64) ([]byte, bool) {
+	if i >= db.num || db.hidden(i) {
+		return nil, false
+	}
+	k := db.getKeySlice(i)
+	return k, k != nil
+}
+
+// EntryAt возвращает копию ключа и распакованного значения записи i.
+// Вместе с Len позволяет выбирать случайные записи равномерно.
+func (db *MMAPDB) EntryAt(i uint64) (Entry, bool, error) {
+	k, ok := db.KeyAt(i)
+	if !ok {
+		return Entry{}, false, nil
+	}
+	v, err := db.value(i)
+	if err != nil {
+		return Entry{}, false, err
+	}
+	return Entry{Key: bytes.Clone(k), Value: bytes.Clone(v)}, true, nil
+}
+
+// CountPrefix возвращает число видимых записей с префиксом prefix. Границы
+// находятся двоичным поиском; в файлах со сроками действия скрытые записи
+// вычитаются проходом по диапазону.
+func (db *MMAPDB) CountPrefix(prefix []byte) uint64 {
+	return db.CountRange(prefix, keyenc.PrefixEnd(prefix))
+}
+
+// CountRange возвращает число видимых записей с ключами из [lo, hi). hi == nil - до конца.
+func (db *MMAPDB) CountRange(lo, hi []byte) uint64 {
+	from, _ := db.findIndex(lo)
+	to := db.num
+	if hi != nil {
+		to, _ = db.findIndex(hi)
+	}
+	if to <= from {
+		return 0
+	}
+	return to - from - db.hiddenIn(from, to)
+}
+
+// hiddenIn возвращает число скрытых записей в [lo, hi). Карта удалений считается
+// по байтам; сроки действия требуют проверки каждой записи.
+func (db *MMAPDB) hiddenIn(lo, hi uint64) uint64 {
+	var n uint64
+	switch {
+	case db.expires != nil:
+		for i := lo; i < hi; i++ {
+			if db.hidden(i) {
+				n++
+			}
+		}
+	case db.tombs != nil:
+		for i := lo; i < hi; {
+			if i%8 == 0 && hi-i >= 8 {
+				n += uint64(bits.OnesCount8(db.tombs[i/8]))
+				i += 8
+				continue
+			}
+			if db.deleted(i) {
+				n++
+			}
+			i++
+		}
+	}
+	return n
+}
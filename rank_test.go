@@ -0,0 +1,92 @@
+package qwick
+
+import (
+	"fmt"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestRankCount(t *testing.T) {
+	dir := t.TempDir()
+	tree := New()
+	for i := range 100 {
+		tree.Insert([]byte(fmt.Sprintf("k%03d", i)), []byte(fmt.Sprint(i)))
+	}
+	tree.Insert([]byte("k010"), Tombstone)
+	tree.Insert([]byte("k011"), Tombstone)
+	tree.Insert([]byte("\xff\xff"), []byte("last"))
+	path := filepath.Join(dir, "rank.qwick")
+	if err := BuildWithOptions(tree, path, BuildOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	db, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	if db.Len() != 101 {
+		t.Errorf("Len = %d", db.Len())
+	}
+	for _, c := range []struct {
+		prefix string
+		want   uint64
+	}{{"", 99}, {"k", 98}, {"k0", 98}, {"k01", 8}, {"k05", 10}, {"x", 0}, {"\xff", 1}} {
+		if got := db.CountPrefix([]byte(c.prefix)); got != c.want {
+			t.Errorf("CountPrefix(%q) = %d, ожидалось %d", c.prefix, got, c.want)
+		}
+	}
+	if got := db.CountRange([]byte("k005"), []byte("k020")); got != 13 {
+		t.Errorf("CountRange = %d", got)
+	}
+	if got := db.CountRange([]byte("k020"), []byte("k005")); got != 0 {
+		t.Errorf("CountRange с пустым диапазоном = %d", got)
+	}
+
+	if i, ok := db.Rank([]byte("k042")); !ok || i != 42 {
+		t.Errorf("Rank(k042) = %d, %v", i, ok)
+	}
+	if i, ok := db.Rank([]byte("k0425")); ok || i != 43 {
+		t.Errorf("Rank(k0425) = %d, %v", i, ok)
+	}
+	if k, ok := db.KeyAt(42); !ok || string(k) != "k042" {
+		t.Errorf("KeyAt(42) = %q, %v", k, ok)
+	}
+	if _, ok := db.KeyAt(10); ok {
+		t.Error("KeyAt вернул удалённую запись")
+	}
+	if _, ok := db.KeyAt(db.Len()); ok {
+		t.Error("KeyAt вне индекса")
+	}
+	if e, ok, err := db.EntryAt(100); err != nil || !ok || string(e.Key) != "\xff\xff" || string(e.Value) != "last" {
+		t.Errorf("EntryAt(100) = %q, %v, %v", e, ok, err)
+	}
+}
+
+func TestCountExpired(t *testing.T) {
+	now := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	tree := New()
+	for i := range 20 {
+		var v any = []byte("v")
+		if i%4 == 0 {
+			v = WithExpiry(v, now.Add(-time.Second))
+		}
+		tree.Insert([]byte(fmt.Sprintf("k%02d", i)), v)
+	}
+	path := filepath.Join(t.TempDir(), "exp.qwick")
+	if err := BuildWithOptions(tree, path, BuildOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	db, err := OpenWithOptions(path, OpenOptions{Now: func() time.Time { return now }})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	if got := db.CountPrefix([]byte("k")); got != 15 {
+		t.Errorf("CountPrefix = %d, ожидалось 15", got)
+	}
+	if got := db.CountRange([]byte("k00"), []byte("k08")); got != 6 {
+		t.Errorf("CountRange = %d, ожидалось 6", got)
+	}
+}
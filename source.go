@@ -0,0 +1,186 @@
+package qwick
+
+import (
+	"container/list"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"sync"
+)
+
+const (
+	readerPageSize  = 4096
+	readerCachePage = 256 // 1MB кэша страниц на базу
+)
+
+// OpenBytes открывает базу данных из среза в памяти (например, из //go:embed).
+// Срез не копируется и не должен изменяться, пока база открыта.
+func OpenBytes(data []byte) (*MMAPDB, error) {
+	return OpenBytesWithOptions(data, OpenOptions{})
+}
+
+// OpenBytesWithOptions открывает базу из среза в памяти с настройками чтения.
+// Настройки страничного кэша (Advice, LockIndex, SequentialScan) здесь не действуют.
+func OpenBytesWithOptions(data []byte, opts OpenOptions) (*MMAPDB, error) {
+	db := &MMAPDB{mdata: data, size: uint64(len(data))}
+	if err := db.init(opts); err != nil {
+		_ = db.Close()
+		return nil, err
+	}
+	return db, nil
+}
+
+// OpenFS открывает базу данных из файловой системы fsys (например, embed.FS).
+// Файл целиком читается в память.
+func OpenFS(fsys fs.FS, name string) (*MMAPDB, error) {
+	return OpenFSWithOptions(fsys, name, OpenOptions{})
+}
+
+// OpenFSWithOptions открывает базу из файловой системы fsys с настройками чтения.
+func OpenFSWithOptions(fsys fs.FS, name string, opts OpenOptions) (*MMAPDB, error) {
+	data, err := fs.ReadFile(fsys, name)
+	if err != nil {
+		return nil, err
+	}
+	return OpenBytesWithOptions(data, opts)
+}
+
+// OpenReaderAt открывает базу данных поверх io.ReaderAt размера size - для платформ
+// и файловых систем без mmap. Чтение идёт через небольшой кэш страниц, поэтому
+// GetRaw и PrefixRaw возвращают срезы кэша или копии, а не срезы mmap.
+// Первая ошибка чтения r запоминается: Find, Prefix, Range и Verify возвращают её
+// и дальше, вместо того чтобы считать ключи отсутствующими. После неё базу
+// нужно открыть заново. Источник r остаётся во владении вызывающего и не закрывается в Close.
+func OpenReaderAt(r io.ReaderAt, size int64) (*MMAPDB, error) {
+	return OpenReaderAtWithOptions(r, size, OpenOptions{})
+}
+
+// OpenReaderAtWithOptions открывает базу поверх io.ReaderAt с настройками чтения.
+// Настройки страничного кэша (Advice, LockIndex, SequentialScan) здесь не действуют.
+func OpenReaderAtWithOptions(r io.ReaderAt, size int64, opts OpenOptions) (*MMAPDB, error) {
+	if size < 0 {
+		return nil, errors.New("отрицательный размер файла")
+	}
+	db := &MMAPDB{
+		size: uint64(size),
+		ra:   newPageReader(r, uint64(size)),
+	}
+	if err := db.init(opts); err != nil {
+		_ = db.Close()
+		return nil, err
+	}
+	return db, nil
+}
+
+// pageReader читает io.ReaderAt страницами и держит последние страницы в LRU-кэше.
+// Буферы страниц не переиспользуются, поэтому выданные срезы остаются валидными.
+type pageReader struct {
+	r    io.ReaderAt
+	size uint64
+
+	mu    sync.Mutex
+	pages map[uint64]*list.Element
+	lru   list.List
+	err   error // первая ошибка чтения
+}
+
+type page struct {
+	id   uint64
+	data []byte
+}
+
+func newPageReader(r io.ReaderAt, size uint64) *pageReader {
+	return &pageReader{r: r, size: size, pages: make(map[uint64]*list.Element)}
+}
+
+// page возвращает страницу с номером id, читая её при промахе.
+func (p *pageReader) page(id uint64) []byte {
+	p.mu.Lock()
+	if el, ok := p.pages[id]; ok {
+		p.lru.MoveToFront(el)
+		p.mu.Unlock()
+		return el.Value.(*page).data
+	}
+	p.mu.Unlock()
+
+	off := id * readerPageSize
+	n := min(uint64(readerPageSize), p.size-off)
+	data := make([]byte, n)
+	if _, err := p.r.ReadAt(data, int64(off)); err != nil && err != io.EOF {
+		p.fail(err)
+		return nil
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if el, ok := p.pages[id]; ok {
+		return el.Value.(*page).data
+	}
+	p.pages[id] = p.lru.PushFront(&page{id: id, data: data})
+	if p.lru.Len() > readerCachePage {
+		el := p.lru.Back()
+		p.lru.Remove(el)
+		delete(p.pages, el.Value.(*page).id)
+	}
+	return data
+}
+
+// slice возвращает n байт начиная с off. Диапазон уже проверен вызывающим.
+func (p *pageReader) slice(off, n uint64) []byte {
+	if n == 0 {
+		return []byte{}
+	}
+	first, last := off/readerPageSize, (off+n-1)/readerPageSize
+	if first == last {
+		pg := p.page(first)
+		start := off - first*readerPageSize
+		if pg == nil || start+n > uint64(len(pg)) {
+			return nil
+		}
+		return pg[start : start+n : start+n]
+	}
+
+	// Крупные диапазоны читаются напрямую, мимо кэша.
+	buf := make([]byte, n)
+	if last-first > 4 {
+		if _, err := p.r.ReadAt(buf, int64(off)); err != nil && err != io.EOF {
+			p.fail(err)
+			return nil
+		}
+		return buf
+	}
+	pos := uint64(0)
+	for id := first; id <= last; id++ {
+		pg := p.page(id)
+		if pg == nil {
+			return nil
+		}
+		start := uint64(0)
+		if id == first {
+			start = off - first*readerPageSize
+		}
+		pos += uint64(copy(buf[pos:], pg[start:]))
+	}
+	return buf
+}
+
+// fail запоминает первую ошибку чтения.
+func (p *pageReader) fail(err error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.err == nil {
+		p.err = fmt.Errorf("ошибка чтения источника: %w", err)
+	}
+}
+
+// readErr возвращает первую ошибку чтения источника базы или nil.
+// У mmap и срезов в памяти ошибок чтения нет.
+func (db *MMAPDB) readErr() error {
+	if db.ra == nil {
+		return nil
+	}
+	db.ra.mu.Lock()
+	defer db.ra.mu.Unlock()
+	return db.ra.err
+}
@@ -0,0 +1,180 @@
+package qwick
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	art "github.com/plar/go-adaptive-radix-tree/v2"
+)
+
+func TestOpenSources(t *testing.T) {
+	tmpDir, _ := os.MkdirTemp("", "qwick_sources")
+	defer os.RemoveAll(tmpDir)
+
+	tree := jsonTree(2000)
+	layouts := []struct {
+		name string
+		opts BuildOptions
+	}{
+		{"Values", BuildOptions{Compression: 0, SizeCutover: 64}},
+		{"Blocks", BuildOptions{Layout: LayoutBlocks}},
+	}
+
+	for _, l := range layouts {
+		dbPath := filepath.Join(tmpDir, l.name+".qwick")
+		if err := BuildWithOptions(tree, dbPath, l.opts); err != nil {
+			t.Fatalf("Ошибка BuildWithOptions: %v", err)
+		}
+		data, _ := os.ReadFile(dbPath)
+		f, err := os.Open(dbPath)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer f.Close()
+
+		open := map[string]func() (*MMAPDB, error){
+			"Bytes":    func() (*MMAPDB, error) { return OpenBytes(data) },
+			"FS":       func() (*MMAPDB, error) { return OpenFS(fstest.MapFS{"db.qwick": {Data: data}}, "db.qwick") },
+			"ReaderAt": func() (*MMAPDB, error) { return OpenReaderAt(f, int64(len(data))) },
+		}
+		for name, fn := range open {
+			t.Run(l.name+"_"+name, func(t *testing.T) {
+				db, err := fn()
+				if err != nil {
+					t.Fatalf("Ошибка открытия: %v", err)
+				}
+				defer db.Close()
+
+				tree.ForEach(func(n art.Node) bool {
+					val, ok, err := db.Find(n.Key(), nil)
+					if !ok || err != nil || string(val) != string(n.Value().([]byte)) {
+						t.Fatalf("Ошибка Find %s: ok %v, err %v", n.Key(), ok, err)
+					}
+					return true
+				})
+
+				count := 0
+				db.PrefixRaw([]byte("user:0015"), func(k, v []byte) bool {
+					count++
+					return true
+				})
+				if count != 100 {
+					t.Errorf("Ошибка PrefixRaw: получено %d", count)
+				}
+			})
+		}
+	}
+}
+
+func TestOpenSourcesErrors(t *testing.T) {
+	if _, err := OpenBytes([]byte("QWICK")); err == nil || err.Error() != "слишком короткий файл" {
+		t.Errorf("Ожидалась ошибка 'слишком короткий файл', получено %v", err)
+	}
+	if _, err := OpenBytes(make([]byte, headerSize)); err == nil {
+		t.Error("Ожидалась ошибка для неверной сигнатуры")
+	}
+	if _, err := OpenFS(fstest.MapFS{}, "missing.qwick"); err == nil {
+		t.Error("Ожидалась ошибка для отсутствующего файла")
+	}
+}
+
+func TestOpenSourcesWithOptions(t *testing.T) {
+	start := time.Unix(1700000000, 0)
+	tree := art.New()
+	tree.Insert([]byte("forever"), []byte("v0"))
+	tree.Insert([]byte("promo"), WithExpiry([]byte("v1"), start.Add(time.Hour)))
+
+	path := filepath.Join(t.TempDir(), "opts.qwick")
+	if err := Build(tree, path); err != nil {
+		t.Fatalf("Ошибка Build: %v", err)
+	}
+	data, _ := os.ReadFile(path)
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	open := map[string]func(OpenOptions) (*MMAPDB, error){
+		"Bytes": func(o OpenOptions) (*MMAPDB, error) { return OpenBytesWithOptions(data, o) },
+		"FS": func(o OpenOptions) (*MMAPDB, error) {
+			return OpenFSWithOptions(fstest.MapFS{"db.qwick": {Data: data}}, "db.qwick", o)
+		},
+		"ReaderAt": func(o OpenOptions) (*MMAPDB, error) { return OpenReaderAtWithOptions(f, int64(len(data)), o) },
+	}
+	for name, fn := range open {
+		t.Run(name, func(t *testing.T) {
+			m := NewMetrics(name)
+			db, err := fn(OpenOptions{
+				CacheBytes: 1 << 20,
+				Observer:   m,
+				Now:        func() time.Time { return start.Add(2 * time.Hour) },
+			})
+			if err != nil {
+				t.Fatalf("Ошибка открытия: %v", err)
+			}
+			defer db.Close()
+
+			if _, ok, _ := db.Find([]byte("promo"), nil); ok {
+				t.Error("Now не учтён: запись promo не истекла")
+			}
+			if _, ok, _ := db.Find([]byte("forever"), nil); !ok {
+				t.Error("Ошибка Find forever")
+			}
+			s := m.Snapshot()
+			if s.Hits["find"] != 1 || s.Misses["find"] != 1 {
+				t.Errorf("Observer не подключён: hits %v, misses %v", s.Hits, s.Misses)
+			}
+		})
+	}
+}
+
+// failingReaderAt отдаёт ошибку на каждое чтение после включения fail.
+type failingReaderAt struct {
+	data []byte
+	fail bool
+}
+
+var errReadFailed = errors.New("диск недоступен")
+
+func (r *failingReaderAt) ReadAt(p []byte, off int64) (int, error) {
+	if r.fail {
+		return 0, errReadFailed
+	}
+	return bytes.NewReader(r.data).ReadAt(p, off)
+}
+
+func TestOpenReaderAtErrors(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "ra.qwick")
+	if err := Build(jsonTree(2000), path); err != nil {
+		t.Fatalf("Ошибка Build: %v", err)
+	}
+	data, _ := os.ReadFile(path)
+	r := &failingReaderAt{data: data}
+	db, err := OpenReaderAt(r, int64(len(data)))
+	if err != nil {
+		t.Fatalf("Ошибка OpenReaderAt: %v", err)
+	}
+	defer db.Close()
+
+	// Страницы значений ещё не прочитаны: ключ не должен молча пропасть.
+	r.fail = true
+	if _, ok, err := db.Find([]byte("user:001000"), nil); ok || !errors.Is(err, errReadFailed) {
+		t.Errorf("Find: ok %v, err %v", ok, err)
+	}
+	noop := func(k, v []byte) bool { return true }
+	if err := db.Prefix([]byte("user:"), nil, noop); !errors.Is(err, errReadFailed) {
+		t.Errorf("Prefix: %v", err)
+	}
+	if err := db.Range(nil, nil, nil, noop); !errors.Is(err, errReadFailed) {
+		t.Errorf("Range: %v", err)
+	}
+	if err := db.Verify(); !errors.Is(err, errReadFailed) {
+		t.Errorf("Verify: %v", err)
+	}
+}
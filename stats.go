@@ -0,0 +1,215 @@
+package qwick
+
+import (
+	"encoding/binary"
+	"math/bits"
+	"time"
+)
+
+// Stats - сводка по содержимому файла. Счётчики записываются при сборке в секцию
+// статистики; гистограммы заполняются только полным обходом (Stats(true)).
+type Stats struct {
+	Entries     uint64 // записи индекса, включая надгробия и повторы ключей
+	Tombstones  uint64 // записи-надгробия
+	KeyBytes    uint64 // суммарная длина ключей
+	MaxKey      uint64 // длина самого длинного ключа
+	ValueBytes  uint64 // суммарная длина значений до сжатия (без Copied)
+	MaxValue    uint64 // длина самого длинного значения
+	StoredBytes uint64 // место, занятое значениями в файле (после сжатия, блоков и Dedupe)
+	IndexBytes  uint64 // размер индекса
+	FileBytes   uint64 // размер файла
+
+	// Число значений по способу хранения; каждое значение учитывается в одном
+	// счётчике: Shared и Copied не входят в Zstd, S2 и Plain. В блочной раскладке
+	// считаются значения, попавшие в блоки с этим кодеком.
+	Zstd   uint64
+	S2     uint64
+	Plain  uint64 // записаны как есть
+	Shared uint64 // записаны ссылкой на ранее записанную копию (Dedupe)
+	Copied uint64 // перенесены слиянием без распаковки
+
+	// Объём значений по кодекам до сжатия и в файле. Значения Shared и Copied
+	// не учитываются; в блочной раскладке Stored - размер сжатых блоков.
+	ZstdBytes  CodecBytes
+	S2Bytes    CodecBytes
+	PlainBytes CodecBytes
+
+	KeySizes   Histogram
+	ValueSizes Histogram
+}
+
+// Histogram - распределение размеров по степеням двойки: элемент i считает размеры
+// из [2^(i-1), 2^i), элемент 0 - пустые.
+type Histogram [65]uint64
+
+func (h *Histogram) add(n uint64) {
+	h[bits.Len64(n)]++
+}
+
+// Ratio возвращает степень сжатия значений (ValueBytes / StoredBytes), 0 - неизвестна.
+func (s Stats) Ratio() float64 {
+	if s.StoredBytes == 0 || s.Copied > 0 {
+		return 0
+	}
+	return float64(s.ValueBytes) / float64(s.StoredBytes)
+}
+
+// CodecBytes - объём значений одного кодека.
+type CodecBytes struct {
+	Raw    uint64 // до сжатия
+	Stored uint64 // в файле
+}
+
+// Ratio возвращает степень сжатия кодека (Raw / Stored), 0 - значений нет.
+func (c CodecBytes) Ratio() float64 {
+	if c.Stored == 0 {
+		return 0
+	}
+	return float64(c.Raw) / float64(c.Stored)
+}
+
+// BuildReport - итоги сборки: показатели Stats и время этапов.
+// Заполняется, если передан в BuildOptions.Report.
+type BuildReport struct {
+	Stats
+	Train  time.Duration // обучение словаря
+	Fill   time.Duration // запись ключей и значений
+	Finish time.Duration // индекс и секции
+	Total  time.Duration
+}
+
+// counters возвращает счётчики в порядке хранения в секции статистики.
+// Новые поля добавляются только в конец: читатель берёт столько, сколько записано.
+func (s *Stats) counters() []*uint64 {
+	return []*uint64{
+		&s.Entries, &s.Tombstones, &s.KeyBytes, &s.MaxKey, &s.ValueBytes, &s.MaxValue,
+		&s.StoredBytes, &s.IndexBytes, &s.Zstd, &s.S2, &s.Plain, &s.Shared, &s.Copied,
+		&s.ZstdBytes.Raw, &s.ZstdBytes.Stored, &s.S2Bytes.Raw, &s.S2Bytes.Stored,
+		&s.PlainBytes.Raw, &s.PlainBytes.Stored,
+	}
+}
+
+func encodeStats(s Stats) []byte {
+	fields := s.counters()
+	out := make([]byte, 8*len(fields))
+	for i, f := range fields {
+		binary.LittleEndian.PutUint64(out[8*i:], *f)
+	}
+	return out
+}
+
+func decodeStats(b []byte, s *Stats) {
+	for i, f := range s.counters() {
+		if len(b) < 8*(i+1) {
+			break
+		}
+		*f = binary.LittleEndian.Uint64(b[8*i:])
+	}
+}
+
+// merge добавляет к s показатели пространства имён.
+func (s *Stats) merge(o Stats) {
+	maxKey, maxValue := max(s.MaxKey, o.MaxKey), max(s.MaxValue, o.MaxValue)
+	fields, other := s.counters(), o.counters()
+	for i, f := range fields {
+		*f += *other[i]
+	}
+	s.MaxKey, s.MaxValue = maxKey, maxValue
+	for i := range s.KeySizes {
+		s.KeySizes[i] += o.KeySizes[i]
+		s.ValueSizes[i] += o.ValueSizes[i]
+	}
+}
+
+// Stats возвращает сводку по файлу. Счётчики читаются из секции статистики;
+// full - дополнительно обойти все записи и построить гистограммы размеров.
+// Для файлов без секции статистики счётчики считаются обходом (кроме числа
+// значений и объёмов по кодекам).
+func (db *MMAPDB) Stats(full bool) (Stats, error) {
+	s := Stats{FileBytes: db.size}
+	stored := db.section(secStats)
+	if stored != nil {
+		decodeStats(stored, &s)
+		if !full {
+			return s, nil
+		}
+	} else {
+		s.Entries = db.num
+		s.IndexBytes = db.num * db.indexSize
+		for i := uint64(0); i < uint64(len(db.blocks)); i += blockRefSize {
+			s.StoredBytes += uint64(binary.LittleEndian.Uint32(db.blocks[i+8:]))
+		}
+	}
+
+	var end uint64 // конец последнего значения, записанного по порядку
+	for i := uint64(0); i < db.num; i++ {
+		e, ok := db.readIndex(i)
+		if !ok {
+			return s, errCorruptValue
+		}
+		s.KeySizes.add(uint64(e.klen))
+		if db.deleted(i) {
+			if stored == nil {
+				s.Tombstones++
+			}
+			continue
+		}
+		n := e.vlen
+		if db.blocks == nil && !e.raw && db.hdr.Flags&flagUncompressed == 0 {
+			v, err := db.value(i)
+			if err != nil {
+				return s, err
+			}
+			n = uint64(len(v))
+		}
+		s.ValueSizes.add(n)
+		if stored != nil {
+			continue
+		}
+		s.KeyBytes += uint64(e.klen)
+		s.MaxKey = max(s.MaxKey, uint64(e.klen))
+		s.ValueBytes += n
+		s.MaxValue = max(s.MaxValue, n)
+		if db.blocks == nil && e.voff >= end {
+			s.StoredBytes += e.vlen
+			end = e.voff + e.vlen
+		}
+	}
+	return s, nil
+}
+
+// countValue учитывает значение в статистике сборки.
+func (b *builder) countValue(key []byte, n int) {
+	b.stats.Entries++
+	b.stats.KeyBytes += uint64(len(key))
+	b.stats.MaxKey = max(b.stats.MaxKey, uint64(len(key)))
+	b.stats.KeySizes.add(uint64(len(key)))
+	b.stats.ValueBytes += uint64(n)
+	b.stats.MaxValue = max(b.stats.MaxValue, uint64(n))
+	b.stats.ValueSizes.add(uint64(n))
+}
+
+// countCodec учитывает кодек, выбранный для значения.
+func (b *builder) countCodec(comp uint32) {
+	switch comp {
+	case compZstd:
+		b.stats.Zstd++
+	case compS2:
+		b.stats.S2++
+	default:
+		b.stats.Plain++
+	}
+}
+
+// countBytes учитывает объём значения (или блока) кодека comp до и после сжатия.
+func (b *builder) countBytes(comp uint32, raw, stored int) {
+	c := &b.stats.PlainBytes
+	switch comp {
+	case compZstd:
+		c = &b.stats.ZstdBytes
+	case compS2:
+		c = &b.stats.S2Bytes
+	}
+	c.Raw += uint64(raw)
+	c.Stored += uint64(stored)
+}
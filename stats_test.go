@@ -0,0 +1,113 @@
+package qwick
+
+import (
+	"encoding/binary"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestStats(t *testing.T) {
+	dir := t.TempDir()
+	tree := New()
+	for i := range 200 {
+		tree.Insert([]byte(fmt.Sprintf("key%03d", i)), []byte(strings.Repeat("v", i%50)))
+	}
+	tree.Insert([]byte("gone"), Tombstone)
+
+	for _, opts := range []BuildOptions{
+		{Compression: 0, SizeCutover: 20},
+		{Compression: compS2, Dedupe: true},
+		{Layout: LayoutBlocks, BlockSize: 512},
+	} {
+		var rep BuildReport
+		opts.Report = &rep
+		path := filepath.Join(dir, "stats.qwick")
+		if err := BuildWithOptions(tree, path, opts); err != nil {
+			t.Fatal(err)
+		}
+		fi, _ := os.Stat(path)
+		if rep.Entries != 201 || rep.Tombstones != 1 || rep.MaxValue != 49 || rep.FileBytes != uint64(fi.Size()) {
+			t.Errorf("%+v: отчёт %+v", opts, rep.Stats)
+		}
+		// Общее значение учитывается только в Shared.
+		if rep.Zstd+rep.S2+rep.Plain+rep.Shared != 200 || rep.Total < rep.Fill {
+			t.Errorf("%+v: кодеки %d/%d/%d/%d, время %v/%v", opts, rep.Zstd, rep.S2, rep.Plain, rep.Shared, rep.Fill, rep.Total)
+		}
+		if opts.SizeCutover > 0 && (rep.Zstd == 0 || rep.S2 == 0) {
+			t.Errorf("авто-режим должен выбрать оба кодека: zstd %d, s2 %d", rep.Zstd, rep.S2)
+		}
+		if opts.Dedupe && rep.Shared != 150 {
+			t.Errorf("Dedupe: общих значений %d, ожидалось 150", rep.Shared)
+		}
+		codecs := []CodecBytes{rep.ZstdBytes, rep.S2Bytes, rep.PlainBytes}
+		var raw, stored uint64
+		for _, c := range codecs {
+			raw, stored = raw+c.Raw, stored+c.Stored
+		}
+		if stored != rep.StoredBytes || !opts.Dedupe && raw != rep.ValueBytes {
+			t.Errorf("%+v: по кодекам %d -> %d байт, всего %d -> %d", opts, raw, stored, rep.ValueBytes, rep.StoredBytes)
+		}
+		if opts.SizeCutover > 0 && rep.ZstdBytes.Ratio() <= 1 {
+			t.Errorf("степень сжатия zstd %.2f", rep.ZstdBytes.Ratio())
+		}
+
+		db, err := Open(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		st, err := db.Stats(false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := rep.Stats
+		want.KeySizes, want.ValueSizes = Histogram{}, Histogram{}
+		if st != want {
+			t.Errorf("Stats(false) = %+v\nожидалось %+v", st, want)
+		}
+		full, err := db.Stats(true)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if full.KeySizes != rep.KeySizes || full.ValueSizes != rep.ValueSizes {
+			t.Errorf("гистограммы %v %v\nожидалось %v %v", full.KeySizes, full.ValueSizes, rep.KeySizes, rep.ValueSizes)
+		}
+		db.Close()
+	}
+}
+
+func TestStatsWithoutSection(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "old.qwick")
+	var rep BuildReport
+	if err := BuildWithOptions(jsonTree(100), path, BuildOptions{Compression: compZstd, Report: &rep}); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Прячем секцию статистики, как в файле старой версии.
+	db, err := OpenBytes(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, s := range db.sections {
+		if s.kind == secStats {
+			binary.LittleEndian.PutUint32(data[db.hdr.OffSections+uint64(i)*sectionEntrySize:], 0xff)
+		}
+	}
+	db, err = OpenBytes(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	st, err := db.Stats(false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if st.Entries != rep.Entries || st.KeyBytes != rep.KeyBytes || st.ValueBytes != rep.ValueBytes ||
+		st.StoredBytes != rep.StoredBytes || st.IndexBytes != rep.IndexBytes || st.ValueSizes != rep.ValueSizes {
+		t.Errorf("Stats без секции = %+v\nожидалось %+v", st, rep.Stats)
+	}
+}
@@ -0,0 +1,144 @@
+package qwick
+
+import (
+	"fmt"
+	"io"
+	"time"
+
+	art "github.com/plar/go-adaptive-radix-tree/v2"
+)
+
+// Typed - типизированный доступ к базе: ключи кодируются KeyCodec, значения - Codec.
+type Typed[K, V any] struct {
+	db   *MMAPDB
+	keys KeyCodec[K]
+	vals Codec[V]
+}
+
+// NewTyped создаёт типизированную обёртку над открытой базой.
+func NewTyped[K, V any](db *MMAPDB, keys KeyCodec[K], vals Codec[V]) *Typed[K, V] {
+	return &Typed[K, V]{db: db, keys: keys, vals: vals}
+}
+
+// Get ищет ключ и декодирует значение.
+func (t *Typed[K, V]) Get(key K) (V, bool, error) {
+	var zero V
+	raw, ok, err := t.db.Find(t.keys.AppendKey(nil, key), nil)
+	if err != nil || !ok {
+		return zero, ok, err
+	}
+	v, err := t.vals.Decode(raw)
+	if err != nil {
+		return zero, false, fmt.Errorf("ошибка декодирования значения: %w", err)
+	}
+	return v, true, nil
+}
+
+// Prefix обходит записи, закодированный ключ которых начинается с prefix.
+// Префикс составного ключа получается кодированием его первых частей, например
+// EscapedStringKey{}.AppendKey(nil, "ru"). Ошибка декодирования прерывает обход.
+func (t *Typed[K, V]) Prefix(prefix []byte, cb func(key K, val V) bool) error {
+	var derr error
+	err := t.db.Prefix(prefix, nil, t.decodeEach(&derr, cb))
+	if derr != nil {
+		return derr
+	}
+	return err
+}
+
+// Range обходит ключи в диапазоне [lo, hi) по порядку.
+func (t *Typed[K, V]) Range(lo, hi K, cb func(key K, val V) bool) error {
+	return t.scan(t.keys.AppendKey(nil, lo), t.keys.AppendKey(nil, hi), cb)
+}
+
+// From обходит ключи начиная с lo и до конца базы.
+func (t *Typed[K, V]) From(lo K, cb func(key K, val V) bool) error {
+	return t.scan(t.keys.AppendKey(nil, lo), nil, cb)
+}
+
+func (t *Typed[K, V]) scan(lo, hi []byte, cb func(key K, val V) bool) error {
+	var derr error
+	err := t.db.Range(lo, hi, nil, t.decodeEach(&derr, cb))
+	if derr != nil {
+		return derr
+	}
+	return err
+}
+
+// decodeEach оборачивает типизированный колбэк; первая ошибка сохраняется в derr.
+func (t *Typed[K, V]) decodeEach(derr *error, cb func(key K, val V) bool) func(k, v []byte) bool {
+	return func(k, v []byte) bool {
+		key, n, err := t.keys.DecodeKey(k)
+		if err == nil && n != len(k) {
+			err = fmt.Errorf("лишние %d байт в ключе", len(k)-n)
+		}
+		if err != nil {
+			*derr = fmt.Errorf("ошибка декодирования ключа %q: %w", k, err)
+			return false
+		}
+		val, err := t.vals.Decode(v)
+		if err != nil {
+			*derr = fmt.Errorf("ошибка декодирования значения %q: %w", k, err)
+			return false
+		}
+		return cb(key, val)
+	}
+}
+
+// TypedBuilder накапливает типизированные пары и собирает из них файл.
+type TypedBuilder[K, V any] struct {
+	tree art.Tree
+	keys KeyCodec[K]
+	vals Codec[V]
+}
+
+// NewTypedBuilder создаёт сборщик с указанными кодеками ключей и значений.
+func NewTypedBuilder[K, V any](keys KeyCodec[K], vals Codec[V]) *TypedBuilder[K, V] {
+	return &TypedBuilder[K, V]{tree: New(), keys: keys, vals: vals}
+}
+
+// Put добавляет или заменяет пару.
+func (b *TypedBuilder[K, V]) Put(key K, val V) error {
+	data, err := b.vals.Encode(val)
+	if err != nil {
+		return fmt.Errorf("ошибка кодирования значения: %w", err)
+	}
+	if data == nil {
+		data = []byte{}
+	}
+	b.tree.Insert(b.keys.AppendKey(nil, key), data)
+	return nil
+}
+
+// PutExpiring добавляет пару со сроком действия до at (см. WithExpiry).
+func (b *TypedBuilder[K, V]) PutExpiring(key K, val V, at time.Time) error {
+	data, err := b.vals.Encode(val)
+	if err != nil {
+		return fmt.Errorf("ошибка кодирования значения: %w", err)
+	}
+	if data == nil {
+		data = []byte{}
+	}
+	b.tree.Insert(b.keys.AppendKey(nil, key), WithExpiry(data, at))
+	return nil
+}
+
+// Delete помечает ключ удалённым (надгробие для дельта-файла).
+func (b *TypedBuilder[K, V]) Delete(key K) {
+	b.tree.Insert(b.keys.AppendKey(nil, key), Tombstone)
+}
+
+// Len возвращает число накопленных ключей.
+func (b *TypedBuilder[K, V]) Len() int {
+	return b.tree.Size()
+}
+
+// Build записывает накопленные пары в файл path.
+func (b *TypedBuilder[K, V]) Build(path string, opts BuildOptions) error {
+	return BuildWithOptions(b.tree, path, opts)
+}
+
+// BuildTo записывает накопленные пары в w (см. BuildTo).
+func (b *TypedBuilder[K, V]) BuildTo(w io.Writer, opts BuildOptions) error {
+	return BuildTo(w, b.tree, opts)
+}
@@ -0,0 +1,95 @@
+package qwick
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestTyped(t *testing.T) {
+	tmpDir, _ := os.MkdirTemp("", "qwick_typed")
+	defer os.RemoveAll(tmpDir)
+
+	type user struct {
+		Name string `json:"name"`
+		Age  int    `json:"age"`
+	}
+	keys := PairKey[string, int64](EscapedStringKey{}, Int64Key{})
+	tb := NewTypedBuilder[Pair[string, int64], user](keys, JSONCodec[user]{})
+	for i := int64(-3); i <= 3; i++ {
+		for _, country := range []string{"ru", "kz"} {
+			if err := tb.Put(Pair[string, int64]{country, i}, user{Name: fmt.Sprintf("%s%d", country, i), Age: int(i)}); err != nil {
+				t.Fatalf("Ошибка Put: %v", err)
+			}
+		}
+	}
+	if tb.Len() != 14 {
+		t.Errorf("Len: %d", tb.Len())
+	}
+	path := filepath.Join(tmpDir, "typed.qwick")
+	if err := tb.Build(path, BuildOptions{Compression: compZstd}); err != nil {
+		t.Fatalf("Ошибка Build: %v", err)
+	}
+	db, err := Open(path)
+	if err != nil {
+		t.Fatalf("Ошибка Open: %v", err)
+	}
+	defer db.Close()
+
+	typed := NewTyped(db, keys, Codec[user](JSONCodec[user]{}))
+	u, ok, err := typed.Get(Pair[string, int64]{"kz", -2})
+	if err != nil || !ok || u.Name != "kz-2" {
+		t.Errorf("Get: %+v, %v, %v", u, ok, err)
+	}
+	if _, ok, _ := typed.Get(Pair[string, int64]{"by", 0}); ok {
+		t.Error("Get: найден отсутствующий ключ")
+	}
+
+	var got []int64
+	err = typed.Prefix(EscapedStringKey{}.AppendKey(nil, "ru"), func(k Pair[string, int64], v user) bool {
+		got = append(got, k.Second)
+		return true
+	})
+	if err != nil || fmt.Sprint(got) != "[-3 -2 -1 0 1 2 3]" {
+		t.Errorf("Prefix: %v, err %v", got, err)
+	}
+
+	got = got[:0]
+	err = typed.Range(Pair[string, int64]{"kz", 2}, Pair[string, int64]{"ru", -2}, func(k Pair[string, int64], v user) bool {
+		got = append(got, int64(v.Age))
+		return true
+	})
+	if err != nil || fmt.Sprint(got) != "[2 3 -3]" {
+		t.Errorf("Range: %v, err %v", got, err)
+	}
+
+	got = got[:0]
+	typed.From(Pair[string, int64]{"ru", 2}, func(k Pair[string, int64], v user) bool {
+		got = append(got, k.Second)
+		return true
+	})
+	if fmt.Sprint(got) != "[2 3]" {
+		t.Errorf("From: %v", got)
+	}
+}
+
+func TestTypedDecodeError(t *testing.T) {
+	var buf bytes.Buffer
+	tree := New()
+	tree.Insert([]byte("short"), []byte("{}"))
+	if err := BuildTo(&buf, tree, BuildOptions{}); err != nil {
+		t.Fatalf("Ошибка BuildTo: %v", err)
+	}
+	db, err := OpenBytes(buf.Bytes())
+	if err != nil {
+		t.Fatalf("Ошибка OpenBytes: %v", err)
+	}
+	defer db.Close()
+
+	typed := NewTyped[uint64, map[string]any](db, Uint64Key{}, JSONCodec[map[string]any]{})
+	if err := typed.Prefix(nil, func(uint64, map[string]any) bool { return true }); err == nil {
+		t.Error("Ожидалась ошибка декодирования ключа")
+	}
+}
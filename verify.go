@@ -0,0 +1,130 @@
+package qwick
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+)
+
+// Verify проверяет целостность файла: хэш содержимого (если он записан), границы
+// записей индекса, порядок ключей и распаковку значений, включая пространства имён.
+// Значение, на которое ссылаются несколько записей (BuildOptions.Dedupe), распаковывается
+// один раз; для остальных ссылок проверяются только границы.
+func (db *MMAPDB) Verify() error {
+	err := db.verify()
+	if rerr := db.readErr(); rerr != nil {
+		// Сбой чтения важнее: испорченные данные могут быть его следствием.
+		return rerr
+	}
+	return err
+}
+
+func (db *MMAPDB) verify() error {
+	if err := db.verifyHash(); err != nil {
+		return err
+	}
+	if err := db.verifyEntries(); err != nil {
+		return err
+	}
+	for _, name := range db.Namespaces() {
+		if err := db.spaces[name].verifyEntries(); err != nil {
+			return fmt.Errorf("пространство имён %q: %w", name, err)
+		}
+	}
+	return nil
+}
+
+// verifyHash сверяет секцию хэша с содержимым файла.
+func (db *MMAPDB) verifyHash() error {
+	for _, s := range db.sections {
+		if s.kind != secHash {
+			continue
+		}
+		if s.off < headerSize {
+			return errors.New("некорректное смещение секции хэша")
+		}
+		h := sha256.New()
+		for off := uint64(headerSize); off < s.off; {
+			n := min(uint64(chunkSize), s.off-off)
+			b := db.at(off, n)
+			if b == nil {
+				return errors.New("ошибка чтения содержимого файла")
+			}
+			h.Write(b)
+			off += n
+		}
+		if !bytes.Equal(h.Sum(nil), db.at(s.off, s.size)) {
+			return errors.New("хэш содержимого не совпадает")
+		}
+	}
+	return nil
+}
+
+// dataRegion возвращает область ключей и значений [lo, hi). В исходной раскладке
+// (версия 1) индекс идёт сразу за заголовком, а данные - после него до конца файла.
+func (db *MMAPDB) dataRegion() (lo, hi uint64) {
+	if db.hdr.OffBlobs > db.hdr.OffIndex {
+		return db.hdr.OffBlobs, db.size
+	}
+	return headerSize, db.hdr.OffIndex
+}
+
+// verifyEntries проверяет записи индекса и значения.
+func (db *MMAPDB) verifyEntries() error {
+	lo, hi := db.dataRegion()
+	var (
+		prev   []byte
+		end    uint64            // конец последнего значения, записанного по порядку
+		shared map[uint64]uint64 // проверенные общие значения: смещение -> длина
+	)
+	for i := uint64(0); i < db.num; i++ {
+		e, ok := db.readIndex(i)
+		if !ok {
+			return fmt.Errorf("запись %d: ошибка чтения индекса", i)
+		}
+		if e.koff < lo || e.koff > hi || uint64(e.klen) > hi-e.koff {
+			return fmt.Errorf("запись %d: ключ вне области данных", i)
+		}
+		key := db.getKeySlice(i)
+		if key == nil {
+			return fmt.Errorf("запись %d: ошибка чтения ключа", i)
+		}
+		if i > 0 {
+			c := bytes.Compare(prev, key)
+			if c > 0 || c == 0 && db.hdr.Flags&flagDups == 0 {
+				return fmt.Errorf("запись %d: нарушен порядок ключей (%q после %q)", i, key, prev)
+			}
+		}
+		prev = key
+
+		if db.deleted(i) {
+			continue
+		}
+		if db.blocks != nil {
+			if _, err := db.blockValue(i); err != nil {
+				return fmt.Errorf("запись %d (%q): %w", i, key, err)
+			}
+			continue
+		}
+		if e.voff < lo || e.voff > hi || e.vlen > hi-e.voff {
+			return fmt.Errorf("запись %d (%q): значение вне области данных", i, key)
+		}
+		// Значения пишутся по порядку; ссылка назад - общее значение.
+		if e.voff < end {
+			if n, ok := shared[e.voff]; ok && n == e.vlen {
+				continue
+			}
+			if shared == nil {
+				shared = make(map[uint64]uint64)
+			}
+			shared[e.voff] = e.vlen
+		} else {
+			end = e.voff + e.vlen
+		}
+		if _, err := db.value(i); err != nil {
+			return fmt.Errorf("запись %d (%q): %w", i, key, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,129 @@
+package qwick
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/klauspost/compress/s2"
+)
+
+func TestVerify(t *testing.T) {
+	dir := t.TempDir()
+	tree := jsonTree(300)
+	tree.Insert([]byte("gone"), Tombstone)
+
+	for i, opts := range []BuildOptions{
+		{Compression: compZstd},
+		{Compression: compS2, Dedupe: true},
+		{Layout: LayoutBlocks, BlockSize: 1024},
+		{SizeCutover: 64, Deterministic: true},
+	} {
+		path := filepath.Join(dir, fmt.Sprintf("v%d.qwick", i))
+		if err := BuildWithOptions(tree, path, opts); err != nil {
+			t.Fatal(err)
+		}
+		db, err := Open(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := db.Verify(); err != nil {
+			t.Errorf("%+v: Verify: %v", opts, err)
+		}
+		db.Close()
+	}
+
+	// Заголовок в конце файла и пространства имён.
+	var buf bytes.Buffer
+	if err := BuildNamespacesTo(&buf, testSpecs(), BuildOptions{Compression: compZstd}); err != nil {
+		t.Fatal(err)
+	}
+	db, err := OpenBytes(buf.Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Verify(); err != nil {
+		t.Errorf("пространства имён: Verify: %v", err)
+	}
+}
+
+func TestVerifyCorrupt(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "c.qwick")
+	if err := BuildWithOptions(jsonTree(100), path, BuildOptions{Compression: compZstd}); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data[headerSize+10] ^= 0xff
+
+	db, err := OpenBytes(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Verify(); err == nil || !strings.Contains(err.Error(), "хэш") {
+		t.Errorf("ожидалась ошибка хэша, получено %v", err)
+	}
+}
+
+// writeLegacy пишет файл в исходной раскладке (версия 1): заголовок, индекс,
+// затем ключи и значения, сжатые S2.
+func writeLegacy(t *testing.T, path string, keys, vals []string) {
+	t.Helper()
+	num := uint64(len(keys))
+	offBlobs := headerSize + num*indexEntrySize
+	var index, blobs []byte
+	for i, k := range keys {
+		v := s2.Encode(nil, []byte(vals[i]))
+		koff := offBlobs + uint64(len(blobs))
+		blobs = append(append(blobs, k...), v...)
+		index = binary.LittleEndian.AppendUint64(index, koff)
+		index = binary.LittleEndian.AppendUint32(index, uint32(len(k)))
+		index = binary.LittleEndian.AppendUint64(index, koff+uint64(len(k)))
+		index = binary.LittleEndian.AppendUint32(index, uint32(len(v)))
+	}
+	hdr := make([]byte, headerSize)
+	copy(hdr, FileMagic)
+	binary.LittleEndian.PutUint32(hdr[8:12], 1)
+	binary.LittleEndian.PutUint64(hdr[16:24], num)
+	binary.LittleEndian.PutUint64(hdr[24:32], headerSize)
+	binary.LittleEndian.PutUint64(hdr[32:40], offBlobs)
+	binary.LittleEndian.PutUint32(hdr[40:44], 100)
+	binary.LittleEndian.PutUint32(hdr[44:48], compS2)
+	if err := os.WriteFile(path, append(append(hdr, index...), blobs...), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestVerifyLegacy(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "v1.qwick")
+	keys := []string{"a", "b", "c"}
+	writeLegacy(t, path, keys, []string{"1", "22", "333"})
+	db, err := Open(path)
+	if err != nil {
+		t.Fatalf("Ошибка Open: %v", err)
+	}
+	defer db.Close()
+	if v, ok, err := db.Find([]byte("c"), nil); !ok || err != nil || string(v) != "333" {
+		t.Fatalf("Find: %q, %v, %v", v, ok, err)
+	}
+	if err := db.Verify(); err != nil {
+		t.Errorf("Verify файла версии 1: %v", err)
+	}
+
+	// Ссылка в область индекса должна отвергаться.
+	data, _ := os.ReadFile(path)
+	binary.LittleEndian.PutUint64(data[headerSize+12:], headerSize)
+	db2, err := OpenBytes(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db2.Verify(); err == nil {
+		t.Error("ожидалась ошибка для значения внутри индекса")
+	}
+}